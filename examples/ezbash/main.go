@@ -19,8 +19,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/threeguys/golang-ezshell/shell"
-	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -46,6 +44,11 @@ func NewEzBash() *EzBash {
 			Description: "lists the files in the current directory",
 			Flags:       shell.FlagOptionalArgs,
 			Handler:     ezb.HandlerList,
+			Arguments:   []*shell.Argument{
+				{ Name: "[path]", Description: "the file or directory to list, defaults to the current directory" },
+			},
+			Examples:    []string{ "ls", "ls /tmp" },
+			Aliases:     []string{ "dir" },
 		},
 		{
 			Name:        "pwd",
@@ -58,12 +61,31 @@ func NewEzBash() *EzBash {
 			Description: "changes the current directory",
 			Flags:       shell.FlagRequiresArgs,
 			Handler:     func (args []string) error { return os.Chdir(args[0]) },
+			Arguments:   []*shell.Argument{
+				{ Name: "<dir>", Description: "the directory to change to" },
+			},
 		},
 		{
 			Name:        "mode",
 			Description: "set the mode to user/debug/admin",
 			Flags:       shell.FlagOptionalArgs,
 			Handler:     ezb.HandlerMode,
+			Completer:   func(args []string) []string {
+				if len(args) == 0 {
+					return []string{ "user", "debug", "admin" }
+				}
+				return nil
+			},
+		},
+		{
+			Name:        "completion",
+			Description: "prints a completion script for bash, zsh or fish",
+			Flags:       shell.FlagRequiresArgs,
+			Handler:     func(args []string) error { return ezb.WriteCompletionScript(ezb.Out, args[0], "ezbash") },
+			Arguments:   []*shell.Argument{
+				{ Name: "<shell>", Description: "bash, zsh or fish" },
+			},
+			Examples:    []string{ "source <(ezbash completion bash)" },
 		},
 		{
 			Name:        "exit",
@@ -117,6 +139,10 @@ func NewEzBash() *EzBash {
 	}
 
 	ezb.Shell = shell.NewCommandShell("ezbash $ ", commands, userMode, debugMode, adminMode)
+	ezb.Scripting = true
+
+	// Commands in ~/.ezbashrc are run before the interactive shell starts
+	ezb.RcFile = "~/.ezbashrc"
 	return ezb
 }
 
@@ -202,26 +228,11 @@ func (ezb *EzBash) HandlerDump(_ []string) error {
 // This handler implements the "exit" command, which just
 // quits the shell
 func (ezb *EzBash) HandlerExit(_ []string) error {
-	os.Exit(0)
-	return nil
-}
-
-// Main entrypoint for the EzBash shell
-func (ezb *EzBash) ShellMain() {
-	if len(os.Args) > 1 {
-		if f, err := os.OpenFile(os.Args[1], os.O_RDONLY, 0); err != nil {
-			log.Fatal(err)
-		} else if err := ezb.RunFile(f); err != nil && err != io.EOF {
-			log.Fatal(err)
-		}
-	} else {
-		if err := ezb.Run(); err != nil {
-			log.Fatal(err)
-		}
-	}
+	return shell.Exit(0)
 }
 
 func main() {
-	ezb := NewEzBash()
-	ezb.ShellMain()
+	// Runs a single command (e.g. "ezbash admin dump"), a script file or
+	// the interactive shell, depending on the arguments
+	NewEzBash().Main()
 }
@@ -17,57 +17,43 @@ package main
 
 import (
 	"github.com/threeguys/golang-ezshell/shell"
-	"log"
 	"os"
 )
 
-func nullHelper(op func()) shell.CommandHandler {
-	return func(_ []string) error {
-		op()
-		return nil
-	}
-}
-
-func argHelper(op func(args []string)) shell.CommandHandler {
-	return func(args []string) error {
-		op(args)
-		return nil
-	}
-}
-
 func main() {
-	var sh *shell.Shell
-	sh = shell.NewCommandShell("$ ", []*shell.Command{
+	sh := shell.NewCommandShell("$ ", []*shell.Command{
 		{
 			Name:        "hello",
 			Description: "say hello",
-			Handler:     argHelper(func(args []string) {
-				if len(args) > 0 {
-					sh.Printf("Hello, %s. How are you?\n", args[0])
+			Invoke:      func(inv *shell.Invocation) error {
+				if len(inv.Args) > 0 {
+					inv.Printf("Hello, %s. How are you?\n", inv.Args[0])
 				} else {
-					sh.Printf("Hello! I am ezbash, type 'help' to see what I can do")
+					inv.Printf("Hello! I am ezbash, type 'help' to see what I can do")
 				}
-			}),
+				return nil
+			},
 		},
 		{
 			Name:        "bye",
 			Description: "quits the shell",
 			Flags:       0,
-			Handler:     nullHelper(func() { os.Exit(0) }),
+			Handler:     func(_ []string) error { return shell.Exit(0) },
 		},
 		{
 			Name:        "move",
 			Description: "moves in a certain direction",
 			Flags:       0,
-			Handler:     argHelper(func(args []string) {
-				if len(args) > 0 {
-					sh.Printf("You move %s\n", args[0])
+			Invoke:      func(inv *shell.Invocation) error {
+				if len(inv.Args) > 0 {
+					inv.Printf("You move %s\n", inv.Args[0])
 				} else {
-					sh.Println("You move in a random direction")
+					inv.Println("You move in a random direction")
 				}
-				sh.Println("You are eaten by a grue.\nPlease try to be more careful")
-			}),
+				inv.Println("You are eaten by a grue.\nPlease try to be more careful")
+				return nil
+			},
 		},
 	})
-	log.Fatal(sh.Run())
+	os.Exit(shell.ExitCode(sh.Run()))
 }
@@ -17,8 +17,6 @@ package main
 
 import (
 	"github.com/threeguys/golang-ezshell/shell"
-	"log"
-	"os"
 )
 
 func main() {
@@ -27,8 +25,8 @@ func main() {
 			Name:        "exit",
 			Description: "quits the shell",
 			Flags:       0,
-			Handler:     func(_ []string) error { os.Exit(0); return nil },
+			Handler:     func(_ []string) error { return shell.Exit(0) },
 		},
 	})
-	log.Fatal(sh.Run())
+	sh.Main()
 }
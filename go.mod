@@ -2,4 +2,4 @@ module github.com/threeguys/golang-ezshell
 
 go 1.16
 
-require github.com/threeguys/golang-toolkit v0.0.0-20200607065220-6fbfaf6f254a // indirect
+require github.com/threeguys/golang-toolkit v0.0.0-20200607065220-6fbfaf6f254a
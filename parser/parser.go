@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 )
 
 const (
@@ -37,8 +38,58 @@ const (
 	StateEndWord
 	StateEOF
 	StateParseError
+	StateOperator
 )
 
+const (
+	TokenWord = iota
+	TokenOperator
+)
+
+// Token is a word or operator read from a line. Quote is the quote character
+// a word was wrapped in, or 0 if it wasn't quoted, and Start and End are the
+// byte offsets of the token in its line, including any quotes. Unterminated
+// is set when the line ended before the closing quote.
+type Token struct {
+	Kind int
+	Text string
+	Quote byte
+	Start int
+	End int
+	Unterminated bool
+}
+
+// inQuotes reports whether the state is inside a quoted word
+func inQuotes(state int) bool {
+	switch state {
+	case StateDblQuote, StateInDblQuote, StateDblEscape, StateSglQuote, StateInSglQuote, StateSglEscape:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsOperator reports whether the token is the operator op
+func (t Token) IsOperator(op string) bool {
+	return t.Kind == TokenOperator && t.Text == op
+}
+
+// IsOperatorChar reports whether c separates words on its own outside quotes
+func IsOperatorChar(c byte) bool {
+	return c == ';' || c == '|'
+}
+
+// Words returns the text of the tokens, dropping empty ones
+func Words(tokens []Token) []string {
+	words := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		if len(t.Text) > 0 {
+			words = append(words, t.Text)
+		}
+	}
+	return words
+}
+
 type CommandReader struct {
 	reader *bufio.Reader
 }
@@ -61,12 +112,22 @@ func (cr *CommandReader) lineFeedOrEOF() error {
 }
 
 func ChangeState(state int, c byte, index int) (int, bool, error) {
+	return changeState(state, c, index, "")
+}
+
+// changeState is ChangeState with the option of treating the operator
+// characters outside quotes as tokens of their own, they are part of a word
+// otherwise
+func changeState(state int, c byte, index int, operators string) (int, bool, error) {
 	if c == '\n' {
 		return StateLineFeed, false, nil
 	}
 
 	switch state {
-	case StateReading, StateEndWord, StateEndSglQuote, StateEndDblQuote:
+	case StateReading, StateEndWord, StateEndSglQuote, StateEndDblQuote, StateOperator:
+		if strings.IndexByte(operators, c) >= 0 {
+			return StateOperator, false, nil
+		}
 		switch c {
 		case '"':
 			return StateDblQuote, false, nil
@@ -96,6 +157,9 @@ func ChangeState(state int, c byte, index int) (int, bool, error) {
 		}
 
 	case StateInWord:
+		if strings.IndexByte(operators, c) >= 0 {
+			return StateOperator, false, nil
+		}
 		switch c {
 		case '"','\'':
 			return StateParseError, false, errors.New(fmt.Sprintf("unexpected [%c] at char %d", c, index))
@@ -138,23 +202,46 @@ func ChangeState(state int, c byte, index int) (int, bool, error) {
 	}
 }
 
-func (cr *CommandReader) Read() ([]string, error) {
-	words := make([]string, 0)
+// ReadTokens reads the next line as word tokens, split the same way as Read.
+// Empty quoted words are kept so the caller can tell them apart from nothing
+// at all.
+func (cr *CommandReader) ReadTokens() ([]Token, error) {
+	return cr.readTokens("")
+}
+
+// ReadScript reads the next line as tokens with ';' and '|' outside quotes
+// returned as operators
+func (cr *CommandReader) ReadScript() ([]Token, error) {
+	return cr.readTokens(";|")
+}
+
+// ReadPipeline reads the next line as tokens with '|' outside quotes
+// returned as an operator, anything else is split the same way as Read
+func (cr *CommandReader) ReadPipeline() ([]Token, error) {
+	return cr.readTokens("|")
+}
+
+func (cr *CommandReader) readTokens(operators string) ([]Token, error) {
+	tokens := make([]Token, 0)
 	current := make([]byte, 0)
 	capturing := false
+	inToken := false
 	state := StateReading
 	index := 0
+	start := 0
+	var quote byte
 	var parseErr error
 
 	for {
 		if c, err := cr.reader.ReadByte(); err != nil && err != io.EOF {
 			return nil, err
 		} else {
+			prev := state
 
 			if err == io.EOF {
 				state = StateEOF
 
-			} else if state, capturing, parseErr = ChangeState(state, c, index); parseErr != nil {
+			} else if state, capturing, parseErr = changeState(state, c, index, operators); parseErr != nil {
 				_ = cr.lineFeedOrEOF()
 				return nil, parseErr
 
@@ -163,16 +250,36 @@ func (cr *CommandReader) Read() ([]string, error) {
 			}
 
 			switch state {
-			case StateLineFeed, StateEndWord, StateEndSglQuote, StateEndDblQuote, StateEOF:
-				if len(current) > 0 {
-					words = append(words, string(current))
+			case StateInWord, StateDblQuote, StateSglQuote:
+				if !inToken {
+					inToken = true
+					start = index
+					quote = 0
+					if state == StateDblQuote {
+						quote = '"'
+					} else if state == StateSglQuote {
+						quote = '\''
+					}
+				}
+
+			case StateLineFeed, StateEndWord, StateEndSglQuote, StateEndDblQuote, StateEOF, StateOperator:
+				if inToken {
+					end := index
+					if state == StateEndSglQuote || state == StateEndDblQuote {
+						end++
+					}
+					tokens = append(tokens, Token{ Kind: TokenWord, Text: string(current), Quote: quote, Start: start, End: end,
+						Unterminated: (state == StateEOF || state == StateLineFeed) && inQuotes(prev) })
 					current = make([]byte, 0)
+					inToken = false
 				}
 
-				if state == StateLineFeed {
-					return words, nil
+				if state == StateOperator {
+					tokens = append(tokens, Token{ Kind: TokenOperator, Text: string([]byte{ c }), Start: index, End: index+1 })
+				} else if state == StateLineFeed {
+					return tokens, nil
 				} else if state == StateEOF {
-					return words, io.EOF
+					return tokens, io.EOF
 				}
 			}
 
@@ -180,3 +287,49 @@ func (cr *CommandReader) Read() ([]string, error) {
 		}
 	}
 }
+
+func (cr *CommandReader) Read() ([]string, error) {
+	tokens, err := cr.ReadTokens()
+	if tokens == nil {
+		return nil, err
+	}
+	return Words(tokens), err
+}
+
+// ParseLine splits a single line into words, an unterminated final quote is
+// treated as if it had been closed
+func ParseLine(line string) ([]string, error) {
+	words, err := NewCommandReader(strings.NewReader(line)).Read()
+	if err == io.EOF {
+		err = nil
+	}
+	return words, err
+}
+
+// Tokenize splits a single line into word tokens, an unterminated final quote
+// is treated as if it had been closed
+func Tokenize(line string) ([]Token, error) {
+	tokens, err := NewCommandReader(strings.NewReader(line)).ReadTokens()
+	if err == io.EOF {
+		err = nil
+	}
+	return tokens, err
+}
+
+// TokenizeScript splits a single line into tokens like ReadScript
+func TokenizeScript(line string) ([]Token, error) {
+	tokens, err := NewCommandReader(strings.NewReader(line)).ReadScript()
+	if err == io.EOF {
+		err = nil
+	}
+	return tokens, err
+}
+
+// TokenizePipeline splits a single line into tokens like ReadPipeline
+func TokenizePipeline(line string) ([]Token, error) {
+	tokens, err := NewCommandReader(strings.NewReader(line)).ReadPipeline()
+	if err == io.EOF {
+		err = nil
+	}
+	return tokens, err
+}
@@ -144,3 +144,76 @@ func TestCommandReader_Read(t *testing.T) {
 	}
 
 }
+
+func TestParseLine(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+
+	words, err := parser.ParseLine(`show "running config" now`)
+	assert.Nil(err)
+	assert.Equal([]string{ "show", "running config", "now" }, words)
+
+	words, err = parser.ParseLine(`echo "unterminated`)
+	assert.Nil(err)
+	assert.Equal([]string{ "echo", "unterminated" }, words)
+
+	words, err = parser.ParseLine("")
+	assert.Nil(err)
+	assert.Equal([]string{}, words)
+
+	_, err = parser.ParseLine(`bad"quote`)
+	assert.Equal(errors.New("unexpected [\"] at char 3"), err)
+}
+
+func TestTokenize(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+
+	tokens, err := parser.TokenizeScript(`if check "a b";then echo 'x;y' ""`)
+	assert.Nil(err)
+	assert.Equal([]parser.Token{
+		{ Kind: parser.TokenWord, Text: "if", Start: 0, End: 2 },
+		{ Kind: parser.TokenWord, Text: "check", Start: 3, End: 8 },
+		{ Kind: parser.TokenWord, Text: "a b", Quote: '"', Start: 9, End: 14 },
+		{ Kind: parser.TokenOperator, Text: ";", Start: 14, End: 15 },
+		{ Kind: parser.TokenWord, Text: "then", Start: 15, End: 19 },
+		{ Kind: parser.TokenWord, Text: "echo", Start: 20, End: 24 },
+		{ Kind: parser.TokenWord, Text: "x;y", Quote: '\'', Start: 25, End: 30 },
+		{ Kind: parser.TokenWord, Text: "", Quote: '"', Start: 31, End: 33 },
+	}, tokens)
+	assert.True(tokens[3].IsOperator(";"))
+	assert.False(tokens[4].IsOperator(";"))
+	assert.Equal([]string{ "if", "check", "a b", ";", "then", "echo", "x;y" }, parser.Words(tokens))
+
+	tokens, err = parser.TokenizeScript(`ls|grep "a|b"`)
+	assert.Nil(err)
+	assert.True(tokens[1].IsOperator("|"))
+	assert.Equal([]string{ "ls", "|", "grep", "a|b" }, parser.Words(tokens))
+	_, err = parser.TokenizeScript(`"foo";bar`)
+	assert.Nil(err)
+
+	// Without operators ';' and '|' are just characters, like they always were
+	words, err := parser.ParseLine("a;b")
+	assert.Nil(err)
+	assert.Equal([]string{ "a;b" }, words)
+	tokens, err = parser.Tokenize(`ls|grep "a|b"`)
+	assert.Nil(err)
+	assert.Equal([]parser.Token{
+		{ Kind: parser.TokenWord, Text: "ls|grep", Start: 0, End: 7 },
+		{ Kind: parser.TokenWord, Text: "a|b", Quote: '"', Start: 8, End: 13 },
+	}, tokens)
+	_, err = parser.Tokenize(`"foo";bar`)
+	assert.Equal(errors.New("expected ' ' at char 5"), err)
+
+	// Pipelines only split on '|'
+	tokens, err = parser.TokenizePipeline(`dump a;b|grep "x|y"`)
+	assert.Nil(err)
+	assert.True(tokens[2].IsOperator("|"))
+	assert.Equal([]string{ "dump", "a;b", "|", "grep", "x|y" }, parser.Words(tokens))
+
+	tokens, err = parser.Tokenize(`echo "not done`)
+	assert.Nil(err)
+	assert.Equal(parser.Token{ Kind: parser.TokenWord, Text: "not done", Quote: '"', Start: 5, End: 14, Unterminated: true }, tokens[1])
+	tokens, err = parser.Tokenize(`echo '`)
+	assert.Nil(err)
+	assert.True(tokens[1].Unterminated)
+	assert.False(tokens[0].Unterminated)
+}
@@ -0,0 +1,82 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"os"
+)
+
+// RunArgs runs a single command given on the command line, optionally
+// prefixed by the name of the mode to run it in, and returns its exit status.
+// A path to a script runs the script, and no arguments or just a mode's name
+// starts the shell.
+func (cs *Shell) RunArgs(args []string) int {
+	if len(args) == 0 {
+		return ExitCode(cs.Run())
+	}
+
+	if len(args) > 1 {
+		if err := cs.SwitchMode(args[0]); err == nil {
+			args = args[1:]
+		}
+	}
+
+	if _, err := cs.CurrentMode().Match(args[0]); err != nil && len(args) == 1 {
+		if cs.SwitchMode(args[0]) == nil {
+			return ExitCode(cs.Run())
+		} else if isScript(args[0]) {
+			return cs.runScript(args[0])
+		}
+	}
+
+	if cs.OnExit != nil {
+		defer cs.OnExit()
+	}
+	err := cs.runRecovered(args)
+	cs.setLastStatus(err)
+	if err != nil {
+		if _, ok := asExitError(err); !ok {
+			cs.printError(err)
+		}
+		return ExitCode(err)
+	}
+	return 0
+}
+
+// Main is the entry point for a program which is both a shell and a regular
+// command line tool, it exits with the status of RunArgs
+func (cs *Shell) Main() {
+	os.Exit(cs.RunArgs(os.Args[1:]))
+}
+
+func isScript(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func (cs *Shell) runScript(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		cs.printError(err)
+		return 1
+	}
+	defer func() { _ = f.Close() }()
+
+	quiet := cs.Quiet
+	cs.Quiet = true
+	defer func() { cs.Quiet = quiet }()
+	return ExitCode(cs.RunFile(f))
+}
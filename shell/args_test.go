@@ -0,0 +1,129 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"errors"
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func createArgsShell(calls *[]string) *shell.Shell {
+	record := func(name string) shell.CommandHandler {
+		return func(args []string) error {
+			*calls = append(*calls, name)
+			*calls = append(*calls, args...)
+			return nil
+		}
+	}
+	admin := &shell.CommandMode{
+		Name:     "admin",
+		Commands: []*shell.Command{ { Name: "dump", Handler: record("dump") } },
+	}
+	cs := shell.NewCommandShell("# ", []*shell.Command{
+		{ Name: "ls", Handler: record("ls") },
+		{ Name: "fail", Handler: func(_ []string) error { return errors.New("failed") } },
+		{ Name: "bye", Handler: func(_ []string) error { return shell.Exit(5) } },
+	}, admin)
+	return cs
+}
+
+func TestShell_RunArgs(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	calls := make([]string, 0)
+	cs := createArgsShell(&calls)
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+	cs.Err = out
+	exits := 0
+	cs.OnExit = func() { exits++ }
+
+	assert.Equal(0, cs.RunArgs([]string{ "ls", "/tmp" }))
+	assert.Equal(0, cs.RunArgs([]string{ "admin", "dump", "PATH" }))
+	assert.Equal([]string{ "ls", "/tmp", "dump", "PATH" }, calls)
+	assert.Equal(1, cs.RunArgs([]string{ "fail" }))
+	assert.Equal(5, cs.RunArgs([]string{ "bye" }))
+	assert.Equal(1, cs.RunArgs([]string{ "nope" }))
+	assert.Equal(5, exits)
+
+	assert.Equal("ERROR: failed\nERROR: no matching command found\n", getLogData(t, out))
+}
+
+func TestShell_RunArgs_Script(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	calls := make([]string, 0)
+	cs := createArgsShell(&calls)
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+
+	script := filepath.Join(t.TempDir(), "script.ez")
+	assert.Nil(ioutil.WriteFile(script, []byte("ls a\nls b\nbye\nls c\n"), 0644))
+
+	assert.Equal(5, cs.RunArgs([]string{ script }))
+	assert.Equal([]string{ "ls", "a", "ls", "b" }, calls)
+	assert.Equal("", getLogData(t, out))
+	assert.False(cs.Quiet)
+}
+
+func TestShell_RunArgs_NoArgs(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	calls := make([]string, 0)
+	cs := createArgsShell(&calls)
+	cs.Quiet = true
+
+	in := makeTempLog(t)
+	defer func() { assert.Nil(in.Close()) }()
+	_, err := in.WriteString("ls x\nfail\n")
+	assert.Nil(err)
+	resetTempFile(t, in)
+
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+
+	cs.In = in
+
+	assert.Equal(0, cs.RunArgs([]string{}))
+	assert.Equal([]string{ "ls", "x" }, calls)
+}
+
+func TestShell_RunArgs_Mode(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	calls := make([]string, 0)
+	cs := createArgsShell(&calls)
+	cs.Quiet = true
+
+	in := makeTempLog(t)
+	defer func() { assert.Nil(in.Close()) }()
+	_, err := in.WriteString("dump x\n")
+	assert.Nil(err)
+	resetTempFile(t, in)
+
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+	cs.In = in
+
+	// Just a mode's name starts the shell in that mode
+	assert.Equal(0, cs.RunArgs([]string{ "admin" }))
+	assert.Equal([]string{ "dump", "x" }, calls)
+	assert.Equal("admin", cs.CurrentMode().Name)
+}
@@ -0,0 +1,190 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"fmt"
+	"github.com/threeguys/golang-ezshell/terminal"
+	"strconv"
+	"strings"
+)
+
+// newBuiltinMode creates the commands every shell has. It sits between the
+// global mode and help, so user commands with the same name take precedence.
+func newBuiltinMode(cs *Shell, delegate *CommandMode) *CommandMode {
+	return &CommandMode{
+		Name:        "builtin",
+		Description: "Built-in commands",
+		Commands:    []*Command{
+			{
+				Name:        "set",
+				Description: "set shell options, -e stops on the first error and +e continues, -o vi or -o emacs picks the editing keys, -o scripting turns on variables, ';' and control flow, name=value sets a variable",
+				Flags:       FlagOptionalArgs,
+				Handler:     cs.builtinSet,
+			},
+			{
+				Name:        "exit",
+				Description: "exit the shell with an optional status code",
+				Flags:       FlagOptionalArgs,
+				Handler:     builtinExit,
+			},
+			{
+				Name:        "quit",
+				Description: "exit the shell with an optional status code",
+				Flags:       FlagOptionalArgs,
+				Handler:     builtinExit,
+			},
+			{
+				Name:        "source",
+				Description: "run a script in the current session",
+				Flags:       FlagRequiresArgs,
+				Handler:     cs.builtinSource,
+				Arguments:   []*Argument{
+					{ Name: "<file>", Description: "the script to run" },
+				},
+			},
+			{
+				Name:        "unset",
+				Description: "remove shell variables",
+				Flags:       FlagRequiresArgs,
+				Handler:     cs.builtinUnset,
+				Arguments:   []*Argument{
+					{ Name: "<name>...", Description: "the variables to remove" },
+				},
+			},
+			{
+				Name:        "theme",
+				Description: "list the styles used for color output, or change one",
+				Flags:       FlagOptionalArgs,
+				Invoke:      cs.builtinTheme,
+				Arguments:   []*Argument{
+					{ Name: "[element]", Description: "one of " + strings.Join(themeElements(), ", ") },
+					{ Name: "[style]...", Description: "words like bold, underline, red, bright-red or on-blue, or none" },
+				},
+				Examples:    []string{ "theme error bold bright-red", "theme prompt none" },
+				Completer:   themeCompleter,
+			},
+			{
+				Name:        "__complete",
+				Description: "print the completions for the arguments, used by completion scripts",
+				Flags:       FlagOptionalArgs,
+				Handler:     cs.builtinComplete,
+				Hidden:      true,
+			},
+		},
+		Delegate:    delegate,
+	}
+}
+
+func (cs *Shell) builtinSet(args []string) error {
+	if len(args) == 0 {
+		cs.lock.RLock()
+		policy, keymap, scripting := cs.ErrorPolicy, cs.Keymap, cs.Scripting
+		cs.lock.RUnlock()
+
+		if policy == StopOnError {
+			cs.Println("set -e")
+		} else {
+			cs.Println("set +e")
+		}
+		if keymap == terminal.ViKeymap {
+			cs.Println("set -o vi")
+		} else {
+			cs.Println("set -o emacs")
+		}
+		if scripting {
+			cs.Println("set -o scripting")
+		} else {
+			cs.Println("set +o scripting")
+		}
+		return nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-e", "+e":
+			policy := ContinueOnError
+			if arg == "-e" {
+				policy = StopOnError
+			}
+			cs.lock.Lock()
+			cs.ErrorPolicy = policy
+			cs.lock.Unlock()
+		case "-o", "+o":
+			if i++; i >= len(args) {
+				return fmt.Errorf("option [%s] needs a name", arg)
+			}
+			cs.lock.Lock()
+			ok := true
+			if args[i] == "scripting" {
+				cs.Scripting = arg == "-o"
+			} else {
+				cs.Keymap, ok = keymapOption(arg, args[i], cs.Keymap)
+			}
+			cs.lock.Unlock()
+			if !ok {
+				return fmt.Errorf("unknown option [%s %s]", arg, args[i])
+			}
+		default:
+			if eq := strings.IndexByte(arg, '='); eq > 0 && IsVarName(arg[:eq]) {
+				cs.SetVar(arg[:eq], arg[eq+1:])
+			} else {
+				return fmt.Errorf("unknown option [%s]", arg)
+			}
+		}
+	}
+	return nil
+}
+
+// keymapOption returns the keymap for set -o vi and set -o emacs. set +o vi
+// goes back to emacs, there's no editing without a keymap so set +o emacs
+// leaves the current one alone.
+func keymapOption(flag string, name string, current terminal.Keymap) (terminal.Keymap, bool) {
+	switch {
+	case name == "vi" && flag == "-o":
+		return terminal.ViKeymap, true
+	case name == "vi", name == "emacs" && flag == "-o":
+		return terminal.EmacsKeymap, true
+	case name == "emacs":
+		return current, true
+	}
+	return current, false
+}
+
+func (cs *Shell) builtinUnset(args []string) error {
+	for _, name := range args {
+		cs.UnsetVar(name)
+	}
+	return nil
+}
+
+func builtinExit(args []string) error {
+	if len(args) == 0 {
+		return Exit(0)
+	} else if code, err := strconv.Atoi(args[0]); err != nil {
+		return fmt.Errorf("invalid exit code [%s]", args[0])
+	} else {
+		return Exit(code)
+	}
+}
+
+func (cs *Shell) builtinComplete(args []string) error {
+	for _, value := range cs.CompleteArgs(args) {
+		cs.Println(value)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"errors"
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-ezshell/terminal"
+	"github.com/threeguys/golang-toolkit/objects"
+	"testing"
+)
+
+func TestBuiltin_Set(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := shell.NewCommandShell("# ", []*shell.Command{})
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+
+	assert.Nil(cs.RunCommand([]string{ "set", "-e" }))
+	assert.Equal(shell.StopOnError, cs.ErrorPolicy)
+	assert.Nil(cs.RunCommand([]string{ "set" }))
+	assert.Nil(cs.RunCommand([]string{ "set", "+e" }))
+	assert.Equal(shell.ContinueOnError, cs.ErrorPolicy)
+	assert.Nil(cs.RunCommand([]string{ "set" }))
+	assert.Equal(errors.New("unknown option [-z]"), cs.RunCommand([]string{ "set", "-z" }))
+
+	assert.Nil(cs.RunCommand([]string{ "set", "-o", "vi" }))
+	assert.Equal(terminal.ViKeymap, cs.Keymap)
+	assert.Nil(cs.RunCommand([]string{ "set" }))
+	assert.Nil(cs.RunCommand([]string{ "set", "+o", "emacs" }))
+	assert.Equal(terminal.ViKeymap, cs.Keymap)
+	assert.Nil(cs.RunCommand([]string{ "set", "+o", "vi" }))
+	assert.Equal(terminal.EmacsKeymap, cs.Keymap)
+	assert.Nil(cs.RunCommand([]string{ "set", "+o", "emacs" }))
+	assert.Equal(terminal.EmacsKeymap, cs.Keymap)
+	assert.Nil(cs.RunCommand([]string{ "set", "-o", "vi" }))
+	assert.Nil(cs.RunCommand([]string{ "set", "-o", "emacs" }))
+	assert.Equal(terminal.EmacsKeymap, cs.Keymap)
+	assert.Equal(errors.New("unknown option [-o nano]"), cs.RunCommand([]string{ "set", "-o", "nano" }))
+	assert.Equal(errors.New("option [-o] needs a name"), cs.RunCommand([]string{ "set", "-o" }))
+
+	assert.Nil(cs.RunCommand([]string{ "set", "-o", "scripting" }))
+	assert.True(cs.Scripting)
+	assert.Nil(cs.RunCommand([]string{ "set" }))
+	assert.Nil(cs.RunCommand([]string{ "set", "+o", "scripting" }))
+	assert.False(cs.Scripting)
+
+	assert.Equal("set -e\nset -o emacs\nset +o scripting\n" +
+		"set +e\nset -o emacs\nset +o scripting\n" +
+		"set +e\nset -o vi\nset +o scripting\n" +
+		"set +e\nset -o emacs\nset -o scripting\n", getLogData(t, out))
+}
+
+func TestBuiltin_Override(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	called := false
+	cs := shell.NewCommandShell("# ", []*shell.Command{
+		{ Name: "set", Handler: func(_ []string) error { called = true; return nil } },
+	})
+
+	assert.Nil(cs.RunCommand([]string{ "set", "-e" }))
+	assert.True(called)
+	assert.Equal(shell.ContinueOnError, cs.ErrorPolicy)
+}
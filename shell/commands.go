@@ -17,6 +17,7 @@ package shell
 
 import (
 	"errors"
+	"os"
 )
 
 var (
@@ -30,13 +31,91 @@ const (
 
 type CommandHandler func([]string) error
 
+// Completer returns the possible values for the argument following args, it
+// is used by tab completion and '?' help
+type Completer func(args []string) []string
+
+// Argument describes one of a command's arguments or options for help
+type Argument struct {
+	Name        string
+	Description string
+}
+
 type Command struct {
 	Name        string
 	Description string
 	Flags       uint32
 	Handler     CommandHandler
+	Invoke      InvocationHandler // used instead of Handler when set
+
+	// Optional fields used by "help <command>" and "<command> --help"
+	Usage           string
+	LongDescription string
+	Arguments       []*Argument
+	Examples        []string
+	Aliases         []string
+
+	// Category groups the command in help listings, Hidden keeps it out of
+	// them and Deprecated is printed as a warning whenever it is run
+	Category   string
+	Hidden     bool
+	Deprecated string
+
+	// NoPager keeps writing the command's output instead of paging it once
+	// it fills the terminal
+	NoPager bool
+
+	Completer Completer
+}
+
+// Matches reports whether the name is the command's name or one of its aliases
+func (cmd *Command) Matches(name string) bool {
+	if cmd.Name == name {
+		return true
+	}
+	for _, alias := range cmd.Aliases {
+		if alias == name {
+			return true
+		}
+	}
+	return false
 }
 
+// Synopsis returns the usage line, built from the arguments if Usage isn't set
+func (cmd *Command) Synopsis() string {
+	if len(cmd.Usage) > 0 {
+		return cmd.Usage
+	}
+	synopsis := cmd.Name
+	for _, arg := range cmd.Arguments {
+		synopsis += " " + arg.Name
+	}
+	return synopsis
+}
+
+// Run runs the command with the process's standard streams, the shell runs
+// commands with its own streams instead
 func (cmd *Command) Run(args []string) error {
-	return cmd.Handler(args)
+	return cmd.invoke(&Invocation{
+		Stdin:   os.Stdin,
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+		Command: cmd,
+		Args:    args,
+	})
+}
+
+// invoke runs the handler and renders what it emitted, even if it failed
+// part way through
+func (cmd *Command) invoke(inv *Invocation) error {
+	var err error
+	if cmd.Invoke != nil {
+		err = cmd.Invoke(inv)
+	} else {
+		err = cmd.Handler(inv.Args)
+	}
+	if renderErr := inv.render(); err == nil {
+		err = renderErr
+	}
+	return err
 }
@@ -40,3 +40,20 @@ func TestCommand_Run(t *testing.T) {
 	assert.Equal(err, errors.New("test error"))
 	assert.Equal([]string { "a", "b", "c" }, args)
 }
+
+func TestCommand_Matches(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cmd := shell.Command{ Name: "list", Aliases: []string{ "ls", "dir" } }
+	assert.True(cmd.Matches("list"))
+	assert.True(cmd.Matches("ls"))
+	assert.True(cmd.Matches("dir"))
+	assert.False(cmd.Matches("lis"))
+}
+
+func TestCommand_Synopsis(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cmd := shell.Command{ Name: "cp", Arguments: []*shell.Argument{ { Name: "<src>" }, { Name: "<dst>" } } }
+	assert.Equal("cp <src> <dst>", cmd.Synopsis())
+	cmd.Usage = "cp [-r] <src>... <dst>"
+	assert.Equal("cp [-r] <src>... <dst>", cmd.Synopsis())
+}
@@ -0,0 +1,193 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"fmt"
+	"github.com/threeguys/golang-ezshell/parser"
+	"strings"
+)
+
+// candidate is a possible next token for a partial line, placeholders
+// describe an argument rather than being something which can be typed
+type candidate struct {
+	name string
+	description string
+	placeholder bool
+}
+
+// tokenizer returns how a typed line is split, with the script operators
+// when scripting is on and otherwise just '|' unless NoPipes is set
+func (cs *Shell) tokenizer() func(string) ([]parser.Token, error) {
+	if cs.scripting() {
+		return parser.TokenizeScript
+	} else if !cs.NoPipes {
+		return parser.TokenizePipeline
+	}
+	return parser.Tokenize
+}
+
+// splitPartial separates the finished words of the last command on the line
+// from the word which is still being typed
+func (cs *Shell) splitPartial(line string) ([]string, string) {
+	var words []string
+	if tokens, err := cs.tokenizer()(line); err != nil {
+		words = strings.Fields(line)
+	} else {
+		start := 0
+		for i, t := range tokens {
+			if t.Kind == parser.TokenOperator {
+				start = i + 1
+			}
+		}
+		words = parser.Words(tokens[start:])
+		if start > 0 && start == len(tokens) {
+			return words, ""
+		}
+	}
+	if len(words) == 0 || strings.HasSuffix(line, " ") || strings.HasSuffix(line, "\t") {
+		return words, ""
+	}
+	return words[:len(words)-1], words[len(words)-1]
+}
+
+// visibleCommands lists the commands in the mode and its delegates, a command
+// hides any with the same name further down the chain
+func visibleCommands(mode *CommandMode) []*Command {
+	seen := make(map[string]bool)
+	commands := make([]*Command, 0)
+	for m := mode; m != nil; m = m.Delegate {
+		for _, c := range m.allCommands() {
+			if !c.Hidden && !seen[c.Name] {
+				seen[c.Name] = true
+				commands = append(commands, c)
+			}
+		}
+	}
+	return commands
+}
+
+// candidates finds what could follow the finished words on the line
+func (cs *Shell) candidates(mode *CommandMode, words []string, partial string) ([]*candidate, error) {
+	found := make([]*candidate, 0)
+	if len(words) == 0 {
+		for _, c := range visibleCommands(mode) {
+			if strings.HasPrefix(c.Name, partial) {
+				found = append(found, &candidate{ name: c.Name, description: c.Description })
+			}
+		}
+		return found, nil
+	}
+
+	cmd, err := mode.Match(words[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.Completer != nil {
+		for _, value := range cmd.Completer(words[1:]) {
+			if strings.HasPrefix(value, partial) {
+				found = append(found, &candidate{ name: value })
+			}
+		}
+	} else if index := len(words) - 1; index < len(cmd.Arguments) {
+		arg := cmd.Arguments[index]
+		found = append(found, &candidate{ name: arg.Name, description: arg.Description, placeholder: true })
+	}
+	return found, nil
+}
+
+// ContextHelp describes what can follow the partial line, like typing '?'
+// on a network device
+func (cs *Shell) ContextHelp(line string) string {
+	words, partial := cs.splitPartial(line)
+	found, err := cs.candidates(cs.CurrentMode(), words, partial)
+	if err != nil {
+		return fmt.Sprintf("%% Unknown command: %s\n", words[0])
+	}
+
+	if len(words) > 0 && len(partial) == 0 {
+		if cmd, err := cs.CurrentMode().Match(words[0]); err == nil && (cmd.Flags & FlagRequiresArgs == 0 || len(words) > 1) {
+			found = append(found, &candidate{ name: "<cr>" })
+		}
+	}
+	if len(found) == 0 {
+		return "% No matches\n"
+	}
+
+	width := 0
+	for _, c := range found {
+		if len(c.name) > width {
+			width = len(c.name)
+		}
+	}
+	sb := &strings.Builder{}
+	for _, c := range found {
+		if len(c.description) > 0 {
+			fmt.Fprintf(sb, "  %-*s  %s\n", width, c.name, c.description)
+		} else {
+			fmt.Fprintf(sb, "  %s\n", c.name)
+		}
+	}
+	return sb.String()
+}
+
+// Complete returns the possible replacements for the last word on the line
+func (cs *Shell) Complete(line string) []string {
+	words, partial := cs.splitPartial(line)
+	found, err := cs.candidates(cs.CurrentMode(), words, partial)
+	return completions(found, err)
+}
+
+func completions(found []*candidate, err error) []string {
+	if err != nil {
+		return nil
+	}
+	values := make([]string, 0, len(found))
+	for _, c := range found {
+		if !c.placeholder {
+			values = append(values, c.name)
+		}
+	}
+	return values
+}
+
+// CompleteArgs completes the last of the arguments the way RunArgs would
+// run them, so the first argument can also be the name of a mode
+func (cs *Shell) CompleteArgs(args []string) []string {
+	if len(args) == 0 {
+		args = []string{ "" }
+	}
+	words, partial := args[:len(args)-1], args[len(args)-1]
+
+	mode := cs.CurrentMode()
+	modes := make([]string, 0)
+	for _, m := range append([]*CommandMode{ cs.Global }, cs.Modes()...) {
+		if len(words) > 0 && words[0] == m.Name {
+			mode = m
+			words = words[1:]
+			break
+		} else if len(words) == 0 && strings.HasPrefix(m.Name, partial) {
+			modes = append(modes, m.Name)
+		}
+	}
+
+	found, err := cs.candidates(mode, words, partial)
+	if values := completions(found, err); values != nil {
+		return append(values, modes...)
+	}
+	return modes
+}
@@ -0,0 +1,108 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrUnknownShell = errors.New("unknown shell, expected bash, zsh or fish")
+)
+
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// topLevel lists the commands and modes which can be the first argument to
+// the program when it is run as a regular command line tool
+func (cs *Shell) topLevel() []*candidate {
+	found, _ := cs.candidates(cs.CurrentMode(), nil, "")
+	for _, m := range append([]*CommandMode{ cs.Global }, cs.Modes()...) {
+		found = append(found, &candidate{ name: m.Name, description: m.Description })
+	}
+	return found
+}
+
+// WriteCompletionScript writes a bash, zsh or fish completion script for the
+// program. The first argument is completed from the commands and modes known
+// now, everything after it is completed by running "prog __complete ...".
+func (cs *Shell) WriteCompletionScript(w io.Writer, shell string, prog string) error {
+	dw := &docWriter{ w: w }
+	fn := "_" + nonIdentifier.ReplaceAllString(prog, "_") + "_complete"
+	top := cs.topLevel()
+
+	switch shell {
+	case "bash":
+		names := make([]string, 0, len(top))
+		for _, c := range top {
+			names = append(names, c.name)
+		}
+		dw.printf("# bash completion for %s\n", prog)
+		dw.printf("%s() {\n", fn)
+		dw.printf("    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+		dw.printf("    if [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+		dw.printf("        COMPREPLY=( $(compgen -W %s -- \"$cur\") )\n", shellQuote(strings.Join(names, " ")))
+		dw.printf("    else\n")
+		dw.printf("        local IFS=$'\\n'\n")
+		dw.printf("        COMPREPLY=( $(%s __complete \"${COMP_WORDS[@]:1:$COMP_CWORD}\" 2>/dev/null) )\n", prog)
+		dw.printf("    fi\n")
+		dw.printf("}\n")
+		dw.printf("complete -F %s %s\n", fn, prog)
+
+	case "zsh":
+		dw.printf("#compdef %s\n", prog)
+		dw.printf("%s() {\n", fn)
+		dw.printf("    if (( CURRENT == 2 )); then\n")
+		dw.printf("        local -a commands\n")
+		dw.printf("        commands=(\n")
+		for _, c := range top {
+			dw.printf("            %s\n", shellQuote(strings.ReplaceAll(c.name, ":", "\\:") + ":" + c.description))
+		}
+		dw.printf("        )\n")
+		dw.printf("        _describe 'command' commands\n")
+		dw.printf("    else\n")
+		dw.printf("        local -a completions\n")
+		dw.printf("        completions=(\"${(@f)$(%s __complete \"${(@)words[2,CURRENT]}\" 2>/dev/null)}\")\n", prog)
+		dw.printf("        compadd -- $completions\n")
+		dw.printf("    fi\n")
+		dw.printf("}\n")
+		dw.printf("if [ \"$funcstack[1]\" = \"%s\" ]; then\n    %s \"$@\"\nelse\n    compdef %s %s\nfi\n", fn, fn, fn, prog)
+
+	case "fish":
+		dw.printf("# fish completion for %s\n", prog)
+		dw.printf("complete -c %s -f\n", prog)
+		for _, c := range top {
+			dw.printf("complete -c %s -n '__fish_use_subcommand' -a %s -d %s\n", prog, fishQuote(c.name), fishQuote(c.description))
+		}
+		dw.printf("complete -c %s -n 'not __fish_use_subcommand' -a '(%s __complete (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)'\n", prog, prog)
+
+	default:
+		return ErrUnknownShell
+	}
+	return dw.err
+}
+
+// shellQuote single quotes the value for bash and zsh
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// fishQuote single quotes the value for fish
+func fishQuote(value string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(value, `\`, `\\`), "'", `\'`) + "'"
+}
@@ -0,0 +1,80 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"bytes"
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"strings"
+	"testing"
+)
+
+func TestShell_CompleteArgs(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createCompletionShell()
+	assert.Nil(cs.SwitchMode("global"))
+
+	assert.Equal([]string{ "show" }, cs.CompleteArgs([]string{ "sho" }))
+	assert.Equal([]string{ "version", "vlan" }, cs.CompleteArgs([]string{ "show", "v" }))
+	assert.Equal([]string{ "config" }, cs.CompleteArgs([]string{ "con" }))
+	assert.Equal([]string{ "set" }, cs.CompleteArgs([]string{ "config", "set" }))
+	assert.Equal([]string{ "version", "vlan" }, cs.CompleteArgs([]string{ "global", "show", "v" }))
+	assert.Equal([]string{}, cs.CompleteArgs([]string{ "bogus", "x" }))
+}
+
+func TestBuiltin_Complete(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createCompletionShell()
+	assert.Nil(cs.SwitchMode("global"))
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+
+	assert.Equal(0, cs.RunArgs([]string{ "__complete", "show", "" }))
+	assert.Equal("version\nvlan\nconfig\n", getLogData(t, out))
+	assert.False(strings.Contains(cs.ContextHelp(""), "__complete"))
+}
+
+func TestShell_WriteCompletionScript(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createCompletionShell()
+	assert.Nil(cs.SwitchMode("global"))
+	out := new(bytes.Buffer)
+
+	assert.Nil(cs.WriteCompletionScript(out, "bash", "net-tool"))
+	script := out.String()
+	assert.True(strings.Contains(script, "_net_tool_complete() {\n"))
+	assert.True(strings.Contains(script, "compgen -W 'show ping where select sort head tail count uniq grep wc cut tee set exit quit "))
+	assert.True(strings.Contains(script, " help global config' -- \"$cur\""))
+	assert.True(strings.Contains(script, "net-tool __complete \"${COMP_WORDS[@]:1:$COMP_CWORD}\""))
+	assert.True(strings.HasSuffix(script, "complete -F _net_tool_complete net-tool\n"))
+
+	out.Reset()
+	assert.Nil(cs.WriteCompletionScript(out, "zsh", "net-tool"))
+	script = out.String()
+	assert.True(strings.HasPrefix(script, "#compdef net-tool\n"))
+	assert.True(strings.Contains(script, "'ping:ping a host'\n"))
+	assert.True(strings.Contains(script, "'help:Display this message'\n"))
+
+	out.Reset()
+	assert.Nil(cs.WriteCompletionScript(out, "fish", "net-tool"))
+	script = out.String()
+	assert.True(strings.Contains(script, "complete -c net-tool -n '__fish_use_subcommand' -a 'show' -d 'show things'\n"))
+	assert.True(strings.Contains(script, "(net-tool __complete (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)"))
+
+	assert.Equal(shell.ErrUnknownShell, cs.WriteCompletionScript(out, "tcsh", "net-tool"))
+}
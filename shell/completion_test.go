@@ -0,0 +1,93 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"testing"
+)
+
+func createCompletionShell() *shell.Shell {
+	show := &shell.Command{
+		Name:        "show",
+		Description: "show things",
+		Flags:       shell.FlagRequiresArgs,
+		Completer:   func(args []string) []string {
+			if len(args) == 0 {
+				return []string{ "version", "vlan", "config" }
+			}
+			return nil
+		},
+	}
+	ping := &shell.Command{
+		Name:        "ping",
+		Description: "ping a host",
+		Arguments:   []*shell.Argument{ { Name: "<host>", Description: "host to ping" } },
+	}
+	secret := &shell.Command{ Name: "shh", Description: "hidden", Hidden: true }
+	configMode := &shell.CommandMode{
+		Name:     "config",
+		Commands: []*shell.Command{ { Name: "set", Description: "set a value" } },
+	}
+	return shell.NewCommandShell("# ", []*shell.Command{ show, ping, secret }, configMode)
+}
+
+func TestShell_ContextHelp(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createCompletionShell()
+	assert.Nil(cs.SwitchMode("global"))
+
+	assert.Equal("  show  show things\n", cs.ContextHelp("sh"))
+	assert.Equal("  version\n  vlan\n  config\n", cs.ContextHelp("show "))
+	assert.Equal("  version\n  vlan\n", cs.ContextHelp("show v"))
+	assert.Equal("  <cr>\n", cs.ContextHelp("show version "))
+	assert.Equal("  <host>  host to ping\n  <cr>\n", cs.ContextHelp("ping "))
+	assert.Equal("% Unknown command: bogus\n", cs.ContextHelp("bogus "))
+	assert.Equal("% No matches\n", cs.ContextHelp("zzz"))
+}
+
+func TestShell_ContextHelp_Mode(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createCompletionShell()
+
+	// The config mode command shadows the builtin set
+	help := cs.ContextHelp("set")
+	assert.Equal("  set  set a value\n", help)
+}
+
+func TestShell_Complete(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createCompletionShell()
+	assert.Nil(cs.SwitchMode("global"))
+
+	assert.Equal([]string{ "show" }, cs.Complete("sho"))
+	assert.Equal([]string{ "version", "vlan" }, cs.Complete("show v"))
+	assert.Equal([]string{}, cs.Complete("ping "))
+	assert.Equal([]string{ "help" }, cs.Complete("hel"))
+	assert.Nil(cs.Complete("bogus "))
+
+	// Only the command after the last pipe matters, unless NoPipes is set
+	assert.Equal([]string{ "where" }, cs.Complete("show v | wh"))
+	assert.Equal([]string{ "version", "vlan" }, cs.Complete("ping x | show v"))
+	assert.True(len(cs.Complete("show |")) > 1)
+	assert.Equal([]string{ "version", "vlan" }, cs.Complete("show v;x | show v"))
+	cs.NoPipes = true
+	assert.Equal([]string{}, cs.Complete("show v | wh"))
+	cs.Scripting = true
+	assert.Equal([]string{ "where" }, cs.Complete("show v | wh"))
+	assert.Equal([]string{ "version", "vlan" }, cs.Complete("show x; show v"))
+}
@@ -0,0 +1,218 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DocFormat selects the output of GenerateDocs
+type DocFormat int
+
+const (
+	DocMarkdown DocFormat = iota
+	DocManPage
+)
+
+// docModes lists the global mode followed by the shell's other modes
+func (cs *Shell) docModes() []*CommandMode {
+	return append([]*CommandMode{ cs.Global }, cs.Modes()...)
+}
+
+// docCommands is everything available in the mode, the global page also
+// covers the built-in and help commands it delegates to
+func (cs *Shell) docCommands(mode *CommandMode) []*commandGroup {
+	if mode == cs.Global {
+		return groupCommands(visibleCommands(mode))
+	}
+	return groupCommands(mode.allCommands())
+}
+
+func docPageName(name string, mode *CommandMode, global *CommandMode) string {
+	if mode == global {
+		return name
+	}
+	return name + "-" + mode.Name
+}
+
+func flagsText(flags uint32) string {
+	if flags & FlagRequiresArgs != 0 {
+		return "requires arguments"
+	} else if flags & FlagOptionalArgs != 0 {
+		return "takes optional arguments"
+	}
+	return ""
+}
+
+// WriteMarkdown writes the documentation for one mode of the shell as
+// Markdown, name is the name of the program
+func (cs *Shell) WriteMarkdown(w io.Writer, name string, mode *CommandMode) error {
+	dw := &docWriter{ w: w }
+	dw.printf("# %s\n\n", docPageName(name, mode, cs.Global))
+	dw.printf("%s\n\n", mode.Description)
+	if mode != cs.Global {
+		dw.printf("Commands available in the `%s` mode, the global commands are also available.\n\n", mode.Name)
+	}
+
+	for _, group := range cs.docCommands(mode) {
+		if len(group.name) > 0 {
+			dw.printf("## %s\n\n", group.name)
+		} else {
+			dw.printf("## Commands\n\n")
+		}
+
+		for _, c := range group.commands {
+			dw.printf("### `%s`\n\n", c.Name)
+			dw.printf("%s\n\n", c.Description)
+			if len(c.Deprecated) > 0 {
+				dw.printf("> **Deprecated:** %s\n\n", c.Deprecated)
+			}
+			dw.printf("**Usage:** `%s`\n\n", c.Synopsis())
+			if len(c.LongDescription) > 0 {
+				dw.printf("%s\n\n", c.LongDescription)
+			}
+			if flags := flagsText(c.Flags); len(flags) > 0 {
+				dw.printf("**Flags:** %s\n\n", flags)
+			}
+			if len(c.Arguments) > 0 {
+				dw.printf("| Argument | Description |\n| --- | --- |\n")
+				for _, arg := range c.Arguments {
+					dw.printf("| `%s` | %s |\n", arg.Name, strings.ReplaceAll(arg.Description, "|", "\\|"))
+				}
+				dw.printf("\n")
+			}
+			if len(c.Aliases) > 0 {
+				dw.printf("**Aliases:** `%s`\n\n", strings.Join(c.Aliases, "`, `"))
+			}
+			if len(c.Examples) > 0 {
+				dw.printf("**Examples:**\n\n```\n%s\n```\n\n", strings.Join(c.Examples, "\n"))
+			}
+		}
+	}
+	return dw.err
+}
+
+// roff escapes text for a man page
+func roff(text string) string {
+	text = strings.ReplaceAll(text, "\\", "\\e")
+	text = strings.ReplaceAll(text, "-", "\\-")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = "\\&" + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WriteManPage writes the documentation for one mode of the shell as a
+// roff man page in the given section
+func (cs *Shell) WriteManPage(w io.Writer, name string, section int, mode *CommandMode) error {
+	dw := &docWriter{ w: w }
+	page := docPageName(name, mode, cs.Global)
+	dw.printf(".TH \"%s\" \"%d\" \"\" \"%s\" \"%s\"\n", strings.ToUpper(page), section, name, roff(mode.Description))
+	dw.printf(".SH NAME\n%s \\- %s\n", roff(page), roff(mode.Description))
+	if mode != cs.Global {
+		dw.printf(".SH DESCRIPTION\nCommands available in the \\fB%s\\fR mode, the global commands are also available.\n", roff(mode.Name))
+	}
+
+	for _, group := range cs.docCommands(mode) {
+		if len(group.name) > 0 {
+			dw.printf(".SH \"%s\"\n", strings.ToUpper(roff(group.name)))
+		} else {
+			dw.printf(".SH COMMANDS\n")
+		}
+
+		for _, c := range group.commands {
+			dw.printf(".TP\n.B %s\n%s\n", roff(c.Synopsis()), roff(c.Description))
+			dw.printf(".RS\n")
+			if len(c.Deprecated) > 0 {
+				dw.printf(".PP\nDeprecated: %s\n", roff(c.Deprecated))
+			}
+			if len(c.LongDescription) > 0 {
+				dw.printf(".PP\n%s\n", roff(c.LongDescription))
+			}
+			if flags := flagsText(c.Flags); len(flags) > 0 {
+				dw.printf(".PP\nThis command %s.\n", roff(flags))
+			}
+			for _, arg := range c.Arguments {
+				dw.printf(".TP\n.I %s\n%s\n", roff(arg.Name), roff(arg.Description))
+			}
+			if len(c.Aliases) > 0 {
+				dw.printf(".PP\nAliases: %s\n", roff(strings.Join(c.Aliases, ", ")))
+			}
+			if len(c.Examples) > 0 {
+				dw.printf(".PP\nExamples:\n.PP\n.nf\n.RS\n%s\n.RE\n.fi\n", roff(strings.Join(c.Examples, "\n")))
+			}
+			dw.printf(".RE\n")
+		}
+	}
+	return dw.err
+}
+
+// GenerateDocs writes a page for the global mode and each of the other
+// modes into the directory, meant to be run from go generate
+func (cs *Shell) GenerateDocs(dir string, name string, format DocFormat) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, mode := range cs.docModes() {
+		page := docPageName(name, mode, cs.Global)
+		var fileName string
+		if format == DocManPage {
+			fileName = page + ".1"
+		} else {
+			fileName = page + ".md"
+		}
+
+		f, err := os.Create(filepath.Join(dir, fileName))
+		if err != nil {
+			return err
+		}
+
+		if format == DocManPage {
+			err = cs.WriteManPage(f, name, 1, mode)
+		} else {
+			err = cs.WriteMarkdown(f, name, mode)
+		}
+
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// docWriter remembers the first error so the page can be written without
+// checking every line
+type docWriter struct {
+	w io.Writer
+	err error
+}
+
+func (dw *docWriter) printf(format string, args ... interface{}) {
+	if dw.err == nil {
+		_, dw.err = fmt.Fprintf(dw.w, format, args...)
+	}
+}
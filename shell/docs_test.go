@@ -0,0 +1,114 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"bytes"
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func createDocsShell() *shell.Shell {
+	admin := &shell.CommandMode{
+		Name:        "admin",
+		Description: "administer things",
+		Commands:    []*shell.Command{
+			{
+				Name:        "dump",
+				Description: "dump the environment",
+				Flags:       shell.FlagOptionalArgs,
+				Arguments:   []*shell.Argument{ { Name: "[prefix]", Description: "only dump matching names" } },
+				Examples:    []string{ "dump", "dump PATH" },
+				Aliases:     []string{ "env" },
+			},
+			{ Name: "secret", Description: "not documented", Hidden: true },
+		},
+	}
+	return shell.NewCommandShell("# ", []*shell.Command{
+		{ Name: "ls", Description: "list files", LongDescription: "Lists -all- the files", Category: "Files" },
+		{ Name: "old", Description: "the old way", Deprecated: "use ls" },
+	}, admin)
+}
+
+func TestShell_WriteMarkdown(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createDocsShell()
+	out := new(bytes.Buffer)
+
+	assert.Nil(cs.WriteMarkdown(out, "tool", cs.Modes()[0]))
+	expected := "# tool-admin\n\n" +
+		"administer things\n\n" +
+		"Commands available in the `admin` mode, the global commands are also available.\n\n" +
+		"## Commands\n\n" +
+		"### `dump`\n\n" +
+		"dump the environment\n\n" +
+		"**Usage:** `dump [prefix]`\n\n" +
+		"**Flags:** takes optional arguments\n\n" +
+		"| Argument | Description |\n| --- | --- |\n" +
+		"| `[prefix]` | only dump matching names |\n\n" +
+		"**Aliases:** `env`\n\n" +
+		"**Examples:**\n\n```\ndump\ndump PATH\n```\n\n"
+	assert.Equal(expected, out.String())
+
+	out.Reset()
+	assert.Nil(cs.WriteMarkdown(out, "tool", cs.Global))
+	global := out.String()
+	assert.True(strings.HasPrefix(global, "# tool\n\nAvailable commands\n\n## Commands\n\n"))
+	assert.True(strings.Contains(global, "### `old`\n\nthe old way\n\n> **Deprecated:** use ls\n\n"))
+	assert.True(strings.Contains(global, "## Files\n\n### `ls`\n\nlist files\n\n**Usage:** `ls`\n\nLists -all- the files\n\n"))
+	assert.True(strings.Contains(global, "### `exit`"))
+	assert.True(strings.Contains(global, "### `help`"))
+}
+
+func TestShell_WriteManPage(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createDocsShell()
+	out := new(bytes.Buffer)
+
+	assert.Nil(cs.WriteManPage(out, "tool", 1, cs.Global))
+	page := out.String()
+	assert.True(strings.HasPrefix(page, ".TH \"TOOL\" \"1\" \"\" \"tool\" \"Available commands\"\n.SH NAME\ntool \\- Available commands\n.SH COMMANDS\n"))
+	assert.True(strings.Contains(page, ".SH \"FILES\"\n.TP\n.B ls\nlist files\n.RS\n.PP\nLists \\-all\\- the files\n.RE\n"))
+
+	out.Reset()
+	assert.Nil(cs.WriteManPage(out, "tool", 8, cs.Modes()[0]))
+	page = out.String()
+	assert.True(strings.HasPrefix(page, ".TH \"TOOL-ADMIN\" \"8\""))
+	assert.True(strings.Contains(page, ".TP\n.I [prefix]\nonly dump matching names\n"))
+	assert.True(strings.Contains(page, ".nf\n.RS\ndump\ndump PATH\n.RE\n.fi\n"))
+	assert.False(strings.Contains(page, "secret"))
+}
+
+func TestShell_GenerateDocs(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createDocsShell()
+	dir := filepath.Join(t.TempDir(), "docs")
+
+	assert.Nil(cs.GenerateDocs(dir, "tool", shell.DocMarkdown))
+	assert.Nil(cs.GenerateDocs(dir, "tool", shell.DocManPage))
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(err)
+	names := make([]string, 0)
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	assert.Equal([]string{ "tool-admin.1", "tool-admin.md", "tool.1", "tool.md" }, names)
+}
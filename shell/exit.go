@@ -0,0 +1,91 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ExitError is returned by a handler to stop the shell, RunSupplier
+// returns it as-is so the code can be handed back to the caller
+type ExitError struct {
+	Code int
+}
+
+func (ee *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", ee.Code)
+}
+
+func (ee *ExitError) ExitCode() int {
+	return ee.Code
+}
+
+// ExitCoder can be implemented by errors returned from handlers to choose
+// the status of the failed command instead of the default of 1
+type ExitCoder interface {
+	ExitCode() int
+}
+
+// StatusError is returned by a handler which failed without anything to
+// report, like grep finding no lines. It sets $? but isn't printed.
+type StatusError int
+
+func (se StatusError) Error() string {
+	return fmt.Sprintf("status %d", int(se))
+}
+
+func (se StatusError) ExitCode() int {
+	return int(se)
+}
+
+// Exit is the error for a handler to return instead of calling os.Exit
+func Exit(code int) error {
+	return &ExitError{ Code: code }
+}
+
+func asExitError(err error) (*ExitError, bool) {
+	var ee *ExitError
+	ok := errors.As(err, &ee)
+	return ee, ok
+}
+
+// ExitCode maps the result of a command or of Run into an exit status,
+// for use as os.Exit(shell.ExitCode(sh.Run()))
+func ExitCode(err error) int {
+	var ec ExitCoder
+	if err == nil {
+		return 0
+	} else if errors.As(err, &ec) {
+		return ec.ExitCode()
+	} else {
+		return 1
+	}
+}
+
+// LastStatus returns the exit status of the last command run, which
+// scripts and the prompt can use as $?
+func (cs *Shell) LastStatus() int {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	return cs.lastStatus
+}
+
+func (cs *Shell) setLastStatus(err error) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.lastStatus = ExitCode(err)
+}
@@ -0,0 +1,115 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/threeguys/golang-ezshell/parser"
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"strings"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	assert.Equal(0, shell.ExitCode(nil))
+	assert.Equal(1, shell.ExitCode(errors.New("failed")))
+	assert.Equal(3, shell.ExitCode(shell.Exit(3)))
+	assert.Equal(4, shell.ExitCode(fmt.Errorf("wrapped: %w", shell.Exit(4))))
+	assert.Equal("exit status 3", shell.Exit(3).Error())
+}
+
+func TestShell_RunSupplier_Exit(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	ran := false
+	cs := shell.NewCommandShell("# ", []*shell.Command{
+		{ Name: "bye", Handler: func(_ []string) error { return shell.Exit(7) } },
+		{ Name: "after", Handler: func(_ []string) error { ran = true; return nil } },
+	})
+	cs.Quiet = true
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+
+	err := cs.RunSupplier(shell.NewListCommandSupplier([]string{ "bye" }, []string{ "after" }))
+	assert.Equal(7, shell.ExitCode(err))
+	assert.False(ran)
+	assert.Equal("", getLogData(t, out))
+}
+
+func TestBuiltin_Exit(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := shell.NewCommandShell("# ", []*shell.Command{})
+	cs.Quiet = true
+
+	run := func(cmd ... string) error {
+		return cs.RunSupplier(shell.NewListCommandSupplier(cmd, []string{ "help" }))
+	}
+
+	assert.Equal(shell.Exit(0), run("exit"))
+	assert.Equal(shell.Exit(42), run("exit", "42"))
+	assert.Equal(shell.Exit(0), run("quit"))
+	assert.Equal(shell.Exit(2), run("quit", "2"))
+	assert.NotNil(cs.RunCommand([]string{ "exit", "nope" }))
+}
+
+type codedError struct {
+	code int
+}
+
+func (ce *codedError) Error() string {
+	return fmt.Sprintf("failed with %d", ce.code)
+}
+
+func (ce *codedError) ExitCode() int {
+	return ce.code
+}
+
+func TestExitCode_ExitCoder(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	assert.Equal(5, shell.ExitCode(&codedError{ 5 }))
+	assert.Equal(6, shell.ExitCode(fmt.Errorf("wrapped: %w", &codedError{ 6 })))
+	assert.Equal(2, shell.ExitCode(&shell.ScriptError{ Line: 1, Err: &codedError{ 2 } }))
+	assert.Equal(3, shell.Exit(3).(shell.ExitCoder).ExitCode())
+}
+
+func TestShell_LastStatus(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs, out := createScriptShell(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Prompt = "[$?] "
+	cs.Quiet = false
+	assert.Nil(cs.AddCommand("global", &shell.Command{
+		Name:    "fail",
+		Flags:   shell.FlagOptionalArgs,
+		Handler: func(args []string) error { return &codedError{ len(args) } },
+	}))
+	assert.Equal(0, cs.LastStatus())
+
+	assert.Nil(cs.RunSupplier(parser.NewCommandReader(strings.NewReader("fail a b\necho $?\nerr\n! noop\necho ${?}\n"))))
+	assert.Equal("[0] ERROR: failed with 2\n[2] 2\n[0] ERROR: i made an error\n[1] SUCCESS\n[1] 1\n[0] ", getLogData(t, out))
+	assert.Equal(0, cs.LastStatus())
+
+	assert.Equal(3, cs.RunArgs([]string{ "fail", "a", "b", "c" }))
+	assert.Equal(3, cs.LastStatus())
+
+	// The prompt is expanded without scripting too
+	cs.Scripting = false
+	assert.Nil(cs.RunSupplier(parser.NewCommandReader(strings.NewReader("fail a\n"))))
+	assert.True(strings.HasSuffix(getLogData(t, out), "[3] ERROR: failed with 1\n[1] "))
+}
@@ -0,0 +1,354 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrNoInput = errors.New("expects input from a pipeline")
+	ErrNoLinesMatched = StatusError(1)
+
+	leadingNumber = regexp.MustCompile(`^\s*[-+]?(\d+\.?\d*|\.\d+)`)
+)
+
+// newTextCommands creates the built-in commands which filter the text
+// written by the previous command in a pipeline
+func newTextCommands() []*Command {
+	return []*Command{
+		{
+			Name:        "grep",
+			Description: "print the lines matching a regular expression",
+			Category:    "Pipeline",
+			Flags:       FlagRequiresArgs,
+			Invoke:      builtinGrep,
+			Arguments:   []*Argument{
+				{ Name: "[-i]", Description: "ignore case" },
+				{ Name: "[-v]", Description: "print the lines which don't match" },
+				{ Name: "<pattern>", Description: "the regular expression to match" },
+			},
+			Examples:    []string{ "dump | grep -i path" },
+		},
+		{
+			Name:        "wc",
+			Description: "count the lines, words and bytes of the input",
+			Category:    "Pipeline",
+			Flags:       FlagOptionalArgs,
+			Invoke:      builtinWc,
+			Arguments:   []*Argument{
+				{ Name: "[-l]", Description: "count lines" },
+				{ Name: "[-w]", Description: "count words" },
+				{ Name: "[-c]", Description: "count bytes" },
+			},
+		},
+		{
+			Name:        "cut",
+			Description: "print selected fields or characters of each line",
+			Category:    "Pipeline",
+			Flags:       FlagRequiresArgs,
+			Invoke:      builtinCut,
+			Arguments:   []*Argument{
+				{ Name: "[-d <delim>]", Description: "the field delimiter, a tab by default" },
+				{ Name: "-f <list>", Description: "the fields to print, such as 1,3 or 2-4" },
+				{ Name: "-c <list>", Description: "the characters to print" },
+			},
+			Examples:    []string{ "dump | cut -d = -f 1" },
+		},
+		{
+			Name:        "tee",
+			Description: "copy the input to a file as well as the output",
+			Category:    "Pipeline",
+			Flags:       FlagRequiresArgs,
+			Invoke:      builtinTee,
+			Arguments:   []*Argument{
+				{ Name: "[-a]", Description: "append to the file" },
+				{ Name: "<file>", Description: "the file to write" },
+			},
+		},
+	}
+}
+
+// parseFlags splits leading single letter flags from the arguments, letters
+// in withValue take the next argument or the rest of the flag as a value.
+// Flags can be combined, as in -iv, and -- ends them.
+func parseFlags(args []string, allowed string, withValue string) (map[byte]string, []string, error) {
+	flags := make(map[byte]string)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return flags, args[i+1:], nil
+		} else if len(arg) < 2 || arg[0] != '-' {
+			return flags, args[i:], nil
+		}
+
+		for j := 1; j < len(arg); j++ {
+			c := arg[j]
+			if strings.IndexByte(withValue, c) >= 0 {
+				if j+1 < len(arg) {
+					flags[c] = arg[j+1:]
+				} else if i+1 < len(args) {
+					i++
+					flags[c] = args[i]
+				} else {
+					return nil, nil, fmt.Errorf("option -%c needs a value", c)
+				}
+				break
+			} else if strings.IndexByte(allowed, c) >= 0 {
+				flags[c] = ""
+			} else {
+				return nil, nil, fmt.Errorf("unknown option -%c", c)
+			}
+		}
+	}
+	return flags, []string{}, nil
+}
+
+// pipedInput returns the output of the previous command in a pipeline. The
+// shell's own input is never read, that would take the rest of a script.
+func pipedInput(inv *Invocation) (io.Reader, error) {
+	if !inv.piped {
+		return nil, fmt.Errorf("%s %w", inv.Command.Name, ErrNoInput)
+	}
+	return inv.Stdin, nil
+}
+
+// readLines reads the piped input as lines without their line feeds
+func readLines(inv *Invocation) ([]string, error) {
+	in, err := pipedInput(inv)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+func printLines(inv *Invocation, lines []string) {
+	if len(lines) > 0 {
+		inv.Printf("%s\n", strings.Join(lines, "\n"))
+	}
+}
+
+func builtinGrep(inv *Invocation) error {
+	flags, args, err := parseFlags(inv.Args, "iv", "")
+	if err != nil {
+		return err
+	} else if len(args) == 0 {
+		return errors.New("usage: grep [-i] [-v] <pattern>")
+	}
+
+	pattern := strings.Join(args, " ")
+	if _, ok := flags['i']; ok {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+
+	lines, err := readLines(inv)
+	if err != nil {
+		return err
+	}
+	_, invert := flags['v']
+	matched := make([]string, 0)
+	for _, line := range lines {
+		if re.MatchString(line) != invert {
+			matched = append(matched, line)
+		}
+	}
+
+	printLines(inv, matched)
+	if len(matched) == 0 {
+		return ErrNoLinesMatched
+	}
+	return nil
+}
+
+func builtinWc(inv *Invocation) error {
+	flags, _, err := parseFlags(inv.Args, "lwc", "")
+	if err != nil {
+		return err
+	}
+	in, err := pipedInput(inv)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	counts := map[byte]int{
+		'l': strings.Count(string(data), "\n"),
+		'w': len(strings.Fields(string(data))),
+		'c': len(data),
+	}
+	fields := make([]string, 0, 3)
+	for _, c := range []byte("lwc") {
+		if _, ok := flags[c]; ok || len(flags) == 0 {
+			fields = append(fields, strconv.Itoa(counts[c]))
+		}
+	}
+	inv.Println(strings.Join(fields, " "))
+	return nil
+}
+
+// parseRanges reads a cut list such as 1,3,5-7 or 3- into ranges, an end
+// of 0 means up to the end of the line
+func parseRanges(list string) ([][2]int, error) {
+	ranges := make([][2]int, 0)
+	for _, part := range strings.Split(list, ",") {
+		var r [2]int
+		var err error
+		bounds := strings.SplitN(part, "-", 2)
+		if len(bounds[0]) == 0 {
+			r[0] = 1
+		} else if r[0], err = strconv.Atoi(bounds[0]); err != nil || r[0] < 1 {
+			return nil, fmt.Errorf("invalid list [%s]", list)
+		}
+
+		if len(bounds) == 1 {
+			r[1] = r[0]
+		} else if len(bounds[1]) > 0 {
+			if r[1], err = strconv.Atoi(bounds[1]); err != nil || r[1] < r[0] {
+				return nil, fmt.Errorf("invalid list [%s]", list)
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func selectRanges(items []string, ranges [][2]int) []string {
+	selected := make([]string, 0)
+	for i := range items {
+		for _, r := range ranges {
+			if i+1 >= r[0] && (r[1] == 0 || i+1 <= r[1]) {
+				selected = append(selected, items[i])
+				break
+			}
+		}
+	}
+	return selected
+}
+
+func builtinCut(inv *Invocation) error {
+	flags, _, err := parseFlags(inv.Args, "", "dfc")
+	if err != nil {
+		return err
+	}
+	fieldList, byField := flags['f']
+	charList, byChar := flags['c']
+	if byField == byChar {
+		return errors.New("usage: cut [-d <delim>] -f <list> | -c <list>")
+	}
+
+	list := fieldList
+	if byChar {
+		list = charList
+	}
+	ranges, err := parseRanges(list)
+	if err != nil {
+		return err
+	}
+	delim, ok := flags['d']
+	if !ok {
+		delim = "\t"
+	}
+
+	lines, err := readLines(inv)
+	if err != nil {
+		return err
+	}
+	for i, line := range lines {
+		if byChar {
+			chars := make([]string, 0, utf8.RuneCountInString(line))
+			for _, r := range line {
+				chars = append(chars, string(r))
+			}
+			lines[i] = strings.Join(selectRanges(chars, ranges), "")
+		} else if strings.Contains(line, delim) {
+			lines[i] = strings.Join(selectRanges(strings.Split(line, delim), ranges), delim)
+		}
+	}
+	printLines(inv, lines)
+	return nil
+}
+
+func builtinTee(inv *Invocation) error {
+	flags, args, err := parseFlags(inv.Args, "a", "")
+	if err != nil {
+		return err
+	} else if len(args) != 1 {
+		return errors.New("usage: tee [-a] <file>")
+	}
+
+	in, err := pipedInput(inv)
+	if err != nil {
+		return err
+	}
+	mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if _, ok := flags['a']; ok {
+		mode = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(expandHome(args[0]), mode, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(io.MultiWriter(f, inv.Stdout), in)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// sortLines sorts text, numerically by the leading number on each line
+// when numeric is set
+func sortLines(lines []string, numeric bool, reverse bool) {
+	less := func(a string, b string) bool {
+		if numeric {
+			x, _ := strconv.ParseFloat(strings.TrimSpace(leadingNumber.FindString(a)), 64)
+			y, _ := strconv.ParseFloat(strings.TrimSpace(leadingNumber.FindString(b)), 64)
+			if x != y {
+				return x < y
+			}
+		}
+		return a < b
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		if reverse {
+			return less(lines[j], lines[i])
+		}
+		return less(lines[i], lines[j])
+	})
+}
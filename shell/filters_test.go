@@ -0,0 +1,148 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"errors"
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runFilterScript(t *testing.T, script string) (string, error) {
+	cs, out := createPipelineShell()
+	err := cs.AddCommand("global", &shell.Command{
+		Name:   "dump",
+		Invoke: func(inv *shell.Invocation) error {
+			inv.Println("PATH=/usr/bin:/bin")
+			inv.Println("HOME=/home/user")
+			inv.Println("SHELL=/bin/ezbash")
+			inv.Println("LINES=10")
+			inv.Println("COLUMNS=132")
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal("Could not add dump", err)
+	}
+	cs.In = strings.NewReader(script)
+	err = cs.Run()
+	return out.String(), err
+}
+
+func TestFilters_Grep(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	out, err := runFilterScript(t, `
+dump | grep PATH
+dump | grep -i ^home
+dump | grep -vi bin
+dump | grep -v -- -x
+dump | grep nothing
+if dump | grep -i shell; then dump | grep COL; fi
+hosts | grep db
+`)
+	assert.Nil(err)
+	assert.Equal("PATH=/usr/bin:/bin\n" +
+		"HOME=/home/user\n" +
+		"HOME=/home/user\nLINES=10\nCOLUMNS=132\n" +
+		"PATH=/usr/bin:/bin\nHOME=/home/user\nSHELL=/bin/ezbash\nLINES=10\nCOLUMNS=132\n" +
+		"SHELL=/bin/ezbash\nCOLUMNS=132\n" +
+		"db1   false  12    map[role:db]\n", out)
+
+	_, err = runFilterScript(t, "set -e\ndump | grep -x a\n")
+	assert.True(err != nil && strings.Contains(err.Error(), "unknown option -x"))
+	_, err = runFilterScript(t, "set -e\ndump | grep (\n")
+	assert.NotNil(err)
+}
+
+func TestFilters_NeedInput(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+
+	// No match is a quiet failure, and without a pipe nothing reads the
+	// rest of the script
+	out, err := runFilterScript(t, `
+if dump | grep nothing; then dump | head 1; else dump | tail 1; fi
+grep PATH
+head
+wc -l
+tee x
+dump | head 1
+`)
+	assert.Nil(err)
+	assert.Equal("COLUMNS=132\n" +
+		"ERROR: grep expects input from a pipeline\n" +
+		"ERROR: head expects input from a pipeline\n" +
+		"ERROR: wc expects input from a pipeline\n" +
+		"ERROR: tee expects input from a pipeline\n" +
+		"PATH=/usr/bin:/bin\n", out)
+
+	cs, out2 := createPipelineShell()
+	cs.In = strings.NewReader("lines | grep x\n")
+	assert.Nil(cs.Run())
+	assert.Equal("", out2.String())
+	assert.Equal(1, cs.LastStatus())
+	assert.True(errors.Is(cs.RunPipeline([]string{ "lines" }, []string{ "grep", "x" }), shell.ErrNoLinesMatched))
+	assert.True(errors.Is(cs.RunCommand([]string{ "cut", "-f", "1" }), shell.ErrNoInput))
+}
+
+func TestFilters_Text(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	out, err := runFilterScript(t, `
+dump | head -2
+dump | tail -n 1
+dump | sort | head 1
+dump | cut -d = -f 2 | sort -r
+dump | cut -d = -f2 | grep ^[0-9] | sort -n
+dump | cut -c 1-3,5- | head -n 1
+dump | wc
+dump | wc -l
+`)
+	assert.Nil(err)
+	assert.Equal("PATH=/usr/bin:/bin\nHOME=/home/user\n" +
+		"COLUMNS=132\n" +
+		"COLUMNS=132\n" +
+		"132\n10\n/usr/bin:/bin\n/home/user\n/bin/ezbash\n" +
+		"10\n132\n" +
+		"PAT=/usr/bin:/bin\n" +
+		"5 5 74\n" +
+		"5\n", out)
+
+	out, err = runFilterScript(t, "dump | cut -f x\ndump | cut -d =\ndump | head z\n")
+	assert.Nil(err)
+	assert.Equal("ERROR: invalid list [x]\n" +
+		"ERROR: usage: cut [-d <delim>] -f <list> | -c <list>\n" +
+		"ERROR: invalid count [z]\n", out)
+}
+
+func TestFilters_Tee(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	path := filepath.Join(t.TempDir(), "tee.out")
+
+	out, err := runFilterScript(t, "dump | head 1 | tee " + path + "\ndump | tail 1 | tee -a " + path + " | wc -l\n")
+	assert.Nil(err)
+	assert.Equal("PATH=/usr/bin:/bin\n1\n", out)
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(err)
+	assert.Equal("PATH=/usr/bin:/bin\nCOLUMNS=132\n", string(data))
+
+	cs, _ := createPipelineShell()
+	assert.Equal(errors.New("usage: tee [-a] <file>"), cs.RunPipeline([]string{ "lines" }, []string{ "tee" }))
+	assert.NotNil(cs.RunPipeline([]string{ "lines" }, []string{ "tee", filepath.Join(path, "not-a-dir", "x") }))
+}
@@ -15,6 +15,14 @@
 //
 package shell
 
+import (
+	"fmt"
+	"github.com/threeguys/golang-ezshell/terminal"
+	"io"
+	"sort"
+	"strings"
+)
+
 func NewHelpMode(help CommandHandler) *CommandMode {
 	return &CommandMode{
 		Name:        "help",
@@ -24,26 +32,209 @@ func NewHelpMode(help CommandHandler) *CommandMode {
 				Name:        "help",
 				Description: "Display this message",
 				Handler:     help,
+				Usage:       "help [command]",
+				Arguments:   []*Argument{
+					{ Name: "[command]", Description: "show the detailed help for a single command" },
+				},
 			},
 		},
 	}
 }
 
-func (cs *Shell) helpHelper(mode *CommandMode) {
-	cs.Printf("  [ (%s) :: %s ]\n    ---> Commands <---\n", mode.Name, mode.Description)
-	for _, c := range mode.Commands {
-		cs.Printf("      %s - %s\n", c.Name, c.Description)
+func (cs *Shell) helpHelper(w io.Writer, mode *CommandMode, theme Theme) {
+	cs.lock.RLock()
+	commands := mode.allCommands()
+	cs.lock.RUnlock()
+
+	fmt.Fprintf(w, "  %s\n", theme.Heading.Apply(fmt.Sprintf("[ (%s) :: %s ]", mode.Name, mode.Description)))
+	for _, group := range groupCommands(commands) {
+		if len(group.name) > 0 {
+			fmt.Fprintf(w, "    %s\n", theme.Heading.Apply("---> " + group.name + " <---"))
+		} else {
+			fmt.Fprintf(w, "    %s\n", theme.Heading.Apply("---> Commands <---"))
+		}
+		for _, c := range group.commands {
+			if len(c.Deprecated) > 0 {
+				fmt.Fprintf(w, "      %s - %s (deprecated)\n", theme.Command.Apply(c.Name), c.Description)
+			} else {
+				fmt.Fprintf(w, "      %s - %s\n", theme.Command.Apply(c.Name), c.Description)
+			}
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+type commandGroup struct {
+	name string
+	commands []*Command
+}
+
+// groupCommands sorts the visible commands into their categories. The
+// uncategorized commands come first in the order they were declared, the
+// categories and the commands in them are alphabetical.
+func groupCommands(commands []*Command) []*commandGroup {
+	index := make(map[string]*commandGroup)
+	groups := make([]*commandGroup, 0)
+	for _, c := range commands {
+		if c.Hidden {
+			continue
+		}
+		group, ok := index[c.Category]
+		if !ok {
+			group = &commandGroup{ name: c.Category }
+			index[c.Category] = group
+			groups = append(groups, group)
+		}
+		group.commands = append(group.commands, c)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].name < groups[j].name })
+	for _, g := range groups {
+		if len(g.name) > 0 {
+			sort.SliceStable(g.commands, func(i, j int) bool { return g.commands[i].Name < g.commands[j].Name })
+		}
 	}
-	cs.Println()
+	return groups
 }
 
 func (cs *Shell) PrintHelp() {
-	cs.Println()
-	cs.helpHelper(cs.Global)
-	if len(cs.modes) > 0 {
-		cs.Printf( "  Current mode: %s\n\n  == Modes ==\n\n", cs.Mode.Name)
-		for _, m := range cs.modes {
-			cs.helpHelper(m)
+	cs.printHelp(printWriter{ cs }, cs.printTheme())
+}
+
+func (cs *Shell) printHelp(w io.Writer, theme Theme) {
+	cs.lock.RLock()
+	current, modes := cs.Mode, cs.modes
+	cs.lock.RUnlock()
+
+	fmt.Fprintln(w)
+	cs.helpHelper(w, cs.Global, theme)
+	cs.helpHelper(w, cs.builtins, theme)
+	if len(modes) > 0 {
+		fmt.Fprintf(w, "  Current mode: %s\n\n  %s\n\n", current.Name, theme.Heading.Apply("== Modes =="))
+		for _, m := range modes {
+			cs.helpHelper(w, m, theme)
+		}
+	}
+}
+
+// helpInvoke shows the overall help, or the help for one command when
+// it is given one. It prints through the invocation so long help is paged.
+func (cs *Shell) helpInvoke(inv *Invocation) error {
+	if len(inv.Args) > 0 {
+		cmd, mode, err := cs.findCommand(inv.Args[0])
+		if err != nil {
+			return err
+		}
+		inv.Printf("%s", formatCommandHelp(cmd, mode, cs.width(), inv.Theme()))
+		return nil
+	}
+	cs.printHelp(inv.Stdout, inv.Theme())
+	return nil
+}
+
+// findCommand looks for the command in the current mode first and then in
+// all of the other modes, so help works for commands in any mode
+func (cs *Shell) findCommand(name string) (*Command, *CommandMode, error) {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	if cmd, mode, err := cs.Mode.Resolve(name); err == nil {
+		return cmd, mode, nil
+	}
+	for _, m := range cs.modes {
+		if cmd, mode, err := m.Resolve(name); err == nil {
+			return cmd, mode, nil
+		}
+	}
+	return nil, nil, ErrNoMatch
+}
+
+func (cs *Shell) width() int {
+	if fd, ok := terminal.Fd(cs.Out); ok {
+		width, _ := terminal.SizeOrDefault(fd)
+		return width
+	}
+	width, _ := terminal.DefaultSize()
+	return width
+}
+
+// PrintCommandHelp prints the usage, description, arguments, aliases and
+// examples of a single command
+func (cs *Shell) PrintCommandHelp(name string) error {
+	cmd, mode, err := cs.findCommand(name)
+	if err != nil {
+		return err
+	}
+	cs.write(formatCommandHelp(cmd, mode, cs.width(), cs.printTheme()))
+	return nil
+}
+
+func formatCommandHelp(cmd *Command, mode *CommandMode, width int, theme Theme) string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "%s %s\n\n", theme.Heading.Apply("Usage:"), cmd.Synopsis())
+	sb.WriteString(wrapText(cmd.Description, width, 2))
+	if len(cmd.LongDescription) > 0 {
+		sb.WriteString("\n")
+		sb.WriteString(wrapText(cmd.LongDescription, width, 2))
+	}
+	if mode != nil {
+		fmt.Fprintf(sb, "\nMode: %s\n", mode.Name)
+	}
+	if len(cmd.Category) > 0 {
+		fmt.Fprintf(sb, "Category: %s\n", cmd.Category)
+	}
+	if len(cmd.Deprecated) > 0 {
+		fmt.Fprintf(sb, "Deprecated: %s\n", cmd.Deprecated)
+	}
+
+	if len(cmd.Arguments) > 0 {
+		sb.WriteString("\n" + theme.Heading.Apply("Arguments:") + "\n")
+		nameWidth := 0
+		for _, arg := range cmd.Arguments {
+			if len(arg.Name) > nameWidth {
+				nameWidth = len(arg.Name)
+			}
+		}
+		for _, arg := range cmd.Arguments {
+			desc := wrapText(arg.Description, width, nameWidth + 6)
+			fmt.Fprintf(sb, "  %-*s    %s", nameWidth, arg.Name, strings.TrimLeft(desc, " "))
+		}
+	}
+
+	if len(cmd.Aliases) > 0 {
+		fmt.Fprintf(sb, "\n%s %s\n", theme.Heading.Apply("Aliases:"), strings.Join(cmd.Aliases, ", "))
+	}
+
+	if len(cmd.Examples) > 0 {
+		sb.WriteString("\n" + theme.Heading.Apply("Examples:") + "\n")
+		for _, example := range cmd.Examples {
+			fmt.Fprintf(sb, "  %s\n", example)
+		}
+	}
+	return sb.String()
+}
+
+// wrapText wraps each line of the text to fit in the width, indenting every
+// line. Words longer than the width are left on a line by themselves.
+func wrapText(text string, width int, indent int) string {
+	sb := &strings.Builder{}
+	prefix := strings.Repeat(" ", indent)
+	for _, line := range strings.Split(text, "\n") {
+		length := 0
+		sb.WriteString(prefix)
+		for _, word := range strings.Fields(line) {
+			if length > 0 && indent + length + 1 + len(word) > width {
+				sb.WriteString("\n")
+				sb.WriteString(prefix)
+				length = 0
+			}
+			if length > 0 {
+				sb.WriteString(" ")
+				length++
+			}
+			sb.WriteString(word)
+			length += len(word)
 		}
+		sb.WriteString("\n")
 	}
+	return sb.String()
 }
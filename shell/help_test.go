@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"os"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -133,3 +134,124 @@ func TestShell_PrintHelp_WithModes(t *testing.T) {
 	expr := `(?s:.*global.* testcmd [^\n]+ testcmddesc[ \n].*testmode.*test-mode-comment.* modecmd [^\n]+ fake-mode-desc[ \n].*)`
 	assertRegexp(assert, regexp.MustCompile(expr), getLogData(t, out))
 }
+
+func createDetailedHelpShell() *shell.Shell {
+	ls := &shell.Command{
+		Name:            "ls",
+		Description:     "lists files",
+		Handler:         func(_ []string) error { return errors.New("should not run") },
+		LongDescription: "Lists all of the files in the directory, or the current directory when no directory is given to it",
+		Arguments:       []*shell.Argument{
+			{ Name: "[dir]", Description: "the directory to list" },
+			{ Name: "-l", Description: "long listing" },
+		},
+		Examples:        []string{ "ls /tmp", "ls -l" },
+		Aliases:         []string{ "dir" },
+	}
+	dump := &shell.Command{ Name: "dump", Description: "dump it all", Usage: "dump > file" }
+	admin := &shell.CommandMode{ Name: "admin", Commands: []*shell.Command{ dump } }
+	return shell.NewCommandShell("# ", []*shell.Command{ ls }, admin)
+}
+
+func TestShell_PrintCommandHelp(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	oldCols := os.Getenv("COLUMNS")
+	defer func() { assert.Nil(os.Setenv("COLUMNS", oldCols)) }()
+	assert.Nil(os.Setenv("COLUMNS", "40"))
+
+	cs := createDetailedHelpShell()
+	assert.Nil(cs.SwitchMode("global"))
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+
+	assert.Nil(cs.RunCommand([]string{ "help", "dir" }))
+	expected := "Usage: ls [dir] -l\n\n" +
+		"  lists files\n\n" +
+		"  Lists all of the files in the\n" +
+		"  directory, or the current directory\n" +
+		"  when no directory is given to it\n\n" +
+		"Mode: global\n\n" +
+		"Arguments:\n" +
+		"  [dir]    the directory to list\n" +
+		"  -l       long listing\n\n" +
+		"Aliases: dir\n\n" +
+		"Examples:\n" +
+		"  ls /tmp\n" +
+		"  ls -l\n"
+	assert.Equal(expected, getLogData(t, out))
+
+	// Help can find commands in other modes
+	assert.Nil(cs.PrintCommandHelp("dump"))
+	assert.True(strings.Contains(getLogData(t, out), "Usage: dump > file\n\n  dump it all\n\nMode: admin\n"))
+	assert.Equal(shell.ErrNoMatch, cs.PrintCommandHelp("nope"))
+}
+
+func TestShell_RunCommand_DashDashHelp(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createDetailedHelpShell()
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+
+	assert.Nil(cs.RunCommand([]string{ "ls", "--help" }))
+	assert.True(strings.HasPrefix(getLogData(t, out), "Usage: ls [dir] -l\n"))
+
+	// Anywhere else --help is passed to the handler as data
+	assert.Equal("should not run", cs.RunCommand([]string{ "ls", "-l", "--help" }).Error())
+	assert.Equal("should not run", cs.RunCommand([]string{ "ls", "--", "--help" }).Error())
+
+	// --help only works for commands available in the current mode
+	assert.Nil(cs.SwitchMode("global"))
+	assert.Equal(shell.ErrNoMatch, cs.RunCommand([]string{ "dump", "--help" }))
+}
+
+func TestShell_PrintHelp_Categories(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := shell.NewCommandShell("# ", []*shell.Command{
+		{ Name: "zap", Description: "zaps", Category: "Danger" },
+		{ Name: "secret", Description: "hidden", Hidden: true, Handler: shell.NoOpHandler() },
+		{ Name: "old", Description: "old way", Deprecated: "use new instead", Handler: shell.NoOpHandler() },
+		{ Name: "boom", Description: "booms", Category: "Danger" },
+		{ Name: "cat", Description: "reads", Category: "Files" },
+		{ Name: "new", Description: "new way", Handler: shell.NoOpHandler() },
+	})
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+
+	cs.PrintHelp()
+	expected := "\n  [ (global) :: Available commands ]\n" +
+		"    ---> Commands <---\n" +
+		"      old - old way (deprecated)\n" +
+		"      new - new way\n" +
+		"    ---> Danger <---\n" +
+		"      boom - booms\n" +
+		"      zap - zaps\n" +
+		"    ---> Files <---\n" +
+		"      cat - reads\n" +
+		"    ---> Pipeline <---\n"
+	assert.True(strings.HasPrefix(getLogData(t, out), expected))
+
+	// Hidden commands still run
+	resetTempFile(t, out)
+	assert.Nil(out.Truncate(0))
+	assert.Nil(cs.RunCommand([]string{ "secret" }))
+	assert.Equal("", getLogData(t, out))
+}
+
+func TestShell_RunCommand_Deprecated(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	ran := false
+	cs := shell.NewCommandShell("# ", []*shell.Command{
+		{ Name: "old", Deprecated: "use new instead", Handler: func(_ []string) error { ran = true; return nil } },
+	})
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+	cs.Err = out
+
+	assert.Nil(cs.RunCommand([]string{ "old" }))
+	assert.True(ran)
+	assert.Equal("WARNING: old is deprecated, use new instead\n", getLogData(t, out))
+}
@@ -0,0 +1,118 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"github.com/threeguys/golang-ezshell/parser"
+	"strings"
+)
+
+// startsCommand is the keywords which are followed by a command
+var startsCommand = map[string]bool{
+	"if": true, "elif": true, "while": true, "then": true, "else": true, "do": true, "{": true, "!": true,
+}
+
+// knownCommand reports whether the name would run something in the
+// current mode, either a command or a function
+func (cs *Shell) knownCommand(name string) bool {
+	if cs.function(name) != nil {
+		return true
+	}
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	_, _, err := cs.Mode.Resolve(name)
+	return err == nil
+}
+
+// Highlight adds the theme's colors to a line the way it will be run, with
+// commands which resolve in the current mode, unknown commands, keywords,
+// quoted strings, variables and operators each in their own style. A quote
+// which hasn't been closed yet is shown as an error. The line is returned
+// as it is when color is off or it doesn't parse.
+func (cs *Shell) Highlight(line string) string {
+	theme := cs.themeFor(cs.Out)
+	if theme == (Theme{}) {
+		return line
+	}
+	scripting := cs.scripting()
+	tokens, err := cs.tokenizer()(line)
+	if err != nil {
+		return line
+	}
+
+	styles := make([]Style, len(line))
+	paint := func(from int, to int, style Style) {
+		for i := from; i < to && i < len(styles); i++ {
+			styles[i] = style
+		}
+	}
+
+	command := true
+	for _, t := range tokens {
+		if t.Kind == parser.TokenOperator {
+			paint(t.Start, t.End, theme.Operator)
+			command = true
+			continue
+		}
+
+		switch {
+		case t.Unterminated:
+			paint(t.Start, t.End, theme.Error)
+		case t.Quote != 0:
+			paint(t.Start, t.End, theme.String)
+			command = false
+		case command && scripting && (keywords[t.Text] || t.Text == "!"):
+			paint(t.Start, t.End, theme.Keyword)
+			command = startsCommand[t.Text]
+		case command && scripting && strings.HasPrefix(t.Text, "$"):
+			command = false
+		case command:
+			if _, _, ok := assignment([]parser.Token{ t }); ok && scripting {
+				paint(t.Start, t.Start + strings.IndexByte(t.Text, '='), theme.Variable)
+			} else if cs.knownCommand(t.Text) {
+				paint(t.Start, t.End, theme.Command)
+			} else {
+				paint(t.Start, t.End, theme.Unknown)
+			}
+			command = false
+		}
+
+		if scripting && t.Quote != '\'' && !t.Unterminated {
+			for i := t.Start; i < t.End; {
+				if name, end := variableAt(line[:t.End], i); len(name) > 0 {
+					paint(i, end, theme.Variable)
+					i = end
+				} else {
+					i++
+				}
+			}
+		}
+	}
+	return applyStyles(line, styles)
+}
+
+// applyStyles wraps each run of bytes with the same style in its codes
+func applyStyles(line string, styles []Style) string {
+	var sb strings.Builder
+	start := 0
+	for i := 1; i <= len(line); i++ {
+		if i == len(line) || styles[i] != styles[start] {
+			sb.WriteString(styles[start].Apply(line[start:i]))
+			start = i
+		}
+	}
+	return sb.String()
+}
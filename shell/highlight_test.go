@@ -0,0 +1,50 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"strings"
+	"testing"
+)
+
+func TestShell_Highlight(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs, _ := createPipelineShell()
+
+	// No color, no highlighting
+	assert.Equal("hosts | nope", cs.Highlight("hosts | nope"))
+
+	cs.Color = shell.ColorAlways
+	cs.In = strings.NewReader("function greet { hosts; }\n")
+	assert.Nil(cs.Run())
+	theme := cs.Theme
+
+	assert.Equal(theme.Command.Apply("hosts") + " " + theme.Operator.Apply("|") + " " + theme.Unknown.Apply("nope"),
+		cs.Highlight("hosts | nope"))
+	assert.Equal(theme.Keyword.Apply("if") + " " + theme.Command.Apply("greet") + theme.Operator.Apply(";") + " " +
+		theme.Keyword.Apply("then") + " " + theme.Command.Apply("help") + " " + theme.String.Apply(`"a `) +
+		theme.Variable.Apply("$x") + theme.String.Apply(`"`) + " " + theme.String.Apply("'$y'") + " " +
+		theme.Variable.Apply("${z}") + "s",
+		cs.Highlight(`if greet; then help "a $x" '$y' ${z}s`))
+	assert.Equal(theme.Variable.Apply("name") + "=value", cs.Highlight("name=value"))
+	assert.Equal(theme.Keyword.Apply("for") + " x in 1 2", cs.Highlight("for x in 1 2"))
+	assert.Equal(theme.Command.Apply("where") + " " + theme.Error.Apply(`"name = `), cs.Highlight(`where "name = `))
+
+	// A line which doesn't parse is left alone
+	assert.Equal(`ab"c`, cs.Highlight(`ab"c`))
+}
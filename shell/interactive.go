@@ -0,0 +1,109 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"github.com/threeguys/golang-ezshell/parser"
+	"github.com/threeguys/golang-ezshell/terminal"
+	"os"
+)
+
+// PromptSupplier is implemented by suppliers which draw the prompt
+// themselves instead of having RunSupplier print it
+type PromptSupplier interface {
+	CommandSupplier
+	SetPrompt(prompt string)
+}
+
+// InteractiveSupplier reads commands from a terminal using a line editor with
+// history, tab completion and '?' help
+type InteractiveSupplier struct {
+	Editor *terminal.LineEditor
+	cs *Shell
+}
+
+type shellWriter struct {
+	cs *Shell
+}
+
+func (sw shellWriter) Write(data []byte) (int, error) {
+	return sw.cs.Out.Write(data)
+}
+
+func (cs *Shell) NewInteractiveSupplier(in *os.File) *InteractiveSupplier {
+	editor := terminal.NewTerminalEditor(in, shellWriter{ cs })
+	editor.Help = cs.ContextHelp
+	editor.Complete = cs.Complete
+	editor.Highlight = cs.Highlight
+	return &InteractiveSupplier{
+		Editor: editor,
+		cs:     cs,
+	}
+}
+
+// SetPrompt sets the prompt and keymap for the next line. Suggestions are
+// only shown when they can be styled, so they aren't mistaken for typed text.
+func (is *InteractiveSupplier) SetPrompt(prompt string) {
+	is.Editor.Prompt = prompt
+	is.cs.lock.RLock()
+	is.Editor.Keymap = is.cs.Keymap
+	is.cs.lock.RUnlock()
+	is.Editor.SuggestionStyle = string(is.cs.themeFor(is.cs.Out).Suggestion)
+	is.Editor.Autosuggest = !is.cs.NoAutosuggest && len(is.Editor.SuggestionStyle) > 0
+}
+
+func (is *InteractiveSupplier) PartialLine() string {
+	return is.Editor.Buffer()
+}
+
+func (is *InteractiveSupplier) PrintAbove(msg string) {
+	is.Editor.PrintAbove(msg)
+}
+
+// Read returns the words of the next line, ctrl-c returns
+// terminal.ErrInterrupted
+func (is *InteractiveSupplier) Read() ([]string, error) {
+	tokens, err := is.readTokens(parser.Tokenize)
+	if tokens == nil {
+		return nil, err
+	}
+	return parser.Words(tokens), err
+}
+
+// ReadScript returns the next line with its operators
+func (is *InteractiveSupplier) ReadScript() ([]parser.Token, error) {
+	return is.readTokens(parser.TokenizeScript)
+}
+
+// ReadPipeline returns the next line with '|' as an operator
+func (is *InteractiveSupplier) ReadPipeline() ([]parser.Token, error) {
+	return is.readTokens(parser.TokenizePipeline)
+}
+
+// readTokens reads a line, a line which doesn't parse is reported and
+// returned as a blank line so a typo doesn't end the session
+func (is *InteractiveSupplier) readTokens(tokenize func(string) ([]parser.Token, error)) ([]parser.Token, error) {
+	line, err := is.Editor.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	if tokens, err := tokenize(line); err != nil {
+		is.cs.printError(err)
+		return []parser.Token{}, nil
+	} else {
+		return tokens, nil
+	}
+}
@@ -0,0 +1,121 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"fmt"
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"strings"
+	"testing"
+)
+
+func TestShell_InteractiveSupplier(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	in := makeTempLog(t)
+	defer func() { assert.Nil(in.Close()) }()
+	_, err := fmt.Fprint(in, "no\t\r" + "bad\"quote\r" + "\x04")
+	assert.Nil(err)
+	resetTempFile(t, in)
+
+	cs := createMockTestShell()
+	cs.Echo = false
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+	cs.Err = out
+
+	is := cs.NewInteractiveSupplier(in)
+	assert.Nil(cs.RunSupplier(is))
+	assert.Equal([]string{ "noop ", "bad\"quote" }, is.Editor.History)
+
+	logs := getLogData(t, out)
+	assert.True(strings.Contains(logs, "\r# noop \x1b[K\r\nSUCCESS\n"))
+	assert.True(strings.Contains(logs, "ERROR: unexpected [\"] at char 3\n"))
+	assert.Equal("", is.PartialLine())
+}
+
+func TestShell_InteractiveSupplier_Autosuggest(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	run := func(noSuggestions bool) string {
+		in := makeTempLog(t)
+		defer func() { assert.Nil(in.Close()) }()
+		_, err := fmt.Fprint(in, "noop\r" + "no\x06\r" + "\x04")
+		assert.Nil(err)
+		resetTempFile(t, in)
+
+		cs := createMockTestShell()
+		cs.Color = shell.ColorAlways
+		cs.NoAutosuggest = noSuggestions
+		out := makeTempLog(t)
+		defer func() { assert.Nil(out.Close()) }()
+		cs.Out = out
+		cs.Err = out
+
+		is := cs.NewInteractiveSupplier(in)
+		assert.Nil(cs.RunSupplier(is))
+		return getLogData(t, out)
+	}
+
+	logs := run(false)
+	assert.True(strings.Contains(logs, "\x1b[2mop\x1b[0m\x1b[2D"))
+	assert.Equal(2, strings.Count(logs, "SUCCESS\n"))
+
+	logs = run(true)
+	assert.False(strings.Contains(logs, "\x1b[2m"))
+	assert.Equal(1, strings.Count(logs, "SUCCESS\n"))
+}
+
+func TestShell_InteractiveSupplier_Vi(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	in := makeTempLog(t)
+	defer func() { assert.Nil(in.Close()) }()
+	_, err := fmt.Fprint(in, "set -o vi\r" + "noopx\x1bx\r" + "\x04")
+	assert.Nil(err)
+	resetTempFile(t, in)
+
+	cs := createMockTestShell()
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+
+	is := cs.NewInteractiveSupplier(in)
+	assert.Nil(cs.RunSupplier(is))
+	assert.Equal([]string{ "set -o vi", "noop" }, is.Editor.History)
+	assert.True(strings.HasSuffix(getLogData(t, out), "\r# noop\x1b[K\x1b[1D\r\nnoop\nSUCCESS\n\r# \x1b[K\r\n"))
+}
+
+func TestShell_InteractiveSupplier_CtrlC(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	in := makeTempLog(t)
+	defer func() { assert.Nil(in.Close()) }()
+	_, err := fmt.Fprint(in, "set -o scripting\r" + "if noop\r" + "then noop\r" + "\x03" + "noop\r" + "\x04")
+	assert.Nil(err)
+	resetTempFile(t, in)
+
+	cs := createMockTestShell()
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+	cs.Err = out
+
+	// ctrl-c throws away the block being typed and goes back to the prompt
+	assert.Nil(cs.RunSupplier(cs.NewInteractiveSupplier(in)))
+	logs := getLogData(t, out)
+	assert.True(strings.Contains(logs, "\r> then noop\x1b[K\r\n\r> \x1b[K^C\r\n\r# \x1b[K"))
+	assert.Equal(1, strings.Count(logs, "SUCCESS\n"))
+	assert.False(strings.Contains(logs, "ERROR"))
+}
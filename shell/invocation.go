@@ -0,0 +1,183 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// InvocationHandler is a handler which is given the whole invocation, so it
+// doesn't need to hold on to the shell to print
+type InvocationHandler func(inv *Invocation) error
+
+// Invocation is a single run of a command. Handlers should print through it
+// instead of the shell, the streams are swapped when the command's input or
+// output is redirected.
+type Invocation struct {
+	Stdin   io.Reader
+	Stdout  io.Writer
+	Stderr  io.Writer
+	Shell   *Shell
+	Mode    *CommandMode
+	Command *Command
+	Args    []string
+	Env     map[string]string
+	Format  OutputFormat
+
+	records []interface{}
+	emitted bool
+	input []interface{}
+	hasInput bool
+	piped bool
+	pipe bool
+	color bool
+}
+
+// Emit adds records to the command's result, which is rendered in Format
+// once the handler returns. Slices add each of their elements.
+func (inv *Invocation) Emit(values ... interface{}) {
+	inv.records = append(inv.records, flatten(values)...)
+	inv.emitted = true
+}
+
+// Input returns the records emitted by the previous command in a pipeline,
+// ok is false when there weren't any
+func (inv *Invocation) Input() (records []interface{}, ok bool) {
+	return inv.input, inv.hasInput
+}
+
+// render writes anything the handler emitted to Stdout. In the middle of a
+// pipeline the records are kept for the next command as well.
+func (inv *Invocation) render() error {
+	if !inv.emitted {
+		return nil
+	}
+	records := inv.records
+	if !inv.pipe {
+		inv.records = nil
+		inv.emitted = false
+	}
+	return render(inv.Stdout, inv.Format, records, inv.Theme())
+}
+
+// Theme returns the shell's theme when Stdout should be styled, otherwise
+// the empty theme so handlers can use its styles without checking
+func (inv *Invocation) Theme() Theme {
+	if !inv.color || inv.Shell == nil {
+		return Theme{}
+	}
+	inv.Shell.lock.RLock()
+	defer inv.Shell.lock.RUnlock()
+	return inv.Shell.Theme
+}
+
+// Styled applies the style to the text when Stdout should be styled
+func (inv *Invocation) Styled(style Style, text string) string {
+	if !inv.color {
+		return text
+	}
+	return style.Apply(text)
+}
+
+func (inv *Invocation) Println(out ... interface{}) {
+	if _, err := fmt.Fprintln(inv.Stdout, out...); err != nil {
+		log.Println("Unable to write to output", err)
+	}
+}
+
+func (inv *Invocation) Printf(fmtStr string, vars ... interface{}) {
+	if _, err := fmt.Fprintf(inv.Stdout, fmtStr, vars...); err != nil {
+		log.Println("Unable to write to output", err)
+	}
+}
+
+// Getenv returns the variable from Env, falling back to the environment
+func (inv *Invocation) Getenv(name string) string {
+	if value, ok := inv.Env[name]; ok {
+		return value
+	}
+	return os.Getenv(name)
+}
+
+// lockedWriter writes to the shell's output or error stream while holding
+// the output lock, the same as Printf on the shell
+type lockedWriter struct {
+	cs *Shell
+	err bool
+}
+
+func (lw lockedWriter) Write(data []byte) (int, error) {
+	lw.cs.outLock.Lock()
+	defer lw.cs.outLock.Unlock()
+	if lw.err {
+		return lw.cs.Err.Write(data)
+	}
+	return lw.cs.Out.Write(data)
+}
+
+// printWriter writes the same way as Printf on the shell
+type printWriter struct {
+	cs *Shell
+}
+
+func (pw printWriter) Write(data []byte) (int, error) {
+	pw.cs.write(string(data))
+	return len(data), nil
+}
+
+// printTheme is the theme for what Printf writes, the running command's if
+// there is one
+func (cs *Shell) printTheme() Theme {
+	cs.outLock.Lock()
+	current := cs.current
+	cs.outLock.Unlock()
+	if current != nil {
+		return current.Theme()
+	}
+	return cs.themeFor(cs.Out)
+}
+
+// newInvocation creates an invocation using the shell's streams, or those of
+// the running command when one runs another
+func (cs *Shell) newInvocation() *Invocation {
+	cs.outLock.Lock()
+	current := cs.current
+	cs.outLock.Unlock()
+	if current != nil {
+		return &Invocation{
+			Stdin:  current.Stdin,
+			Stdout: current.Stdout,
+			Stderr: current.Stderr,
+			Shell:  cs,
+			Env:    cs.Vars(),
+			Format: cs.Format,
+			piped:  current.piped,
+			color:  current.color,
+		}
+	}
+	return &Invocation{
+		Stdin:  cs.In,
+		Stdout: lockedWriter{ cs: cs },
+		Stderr: lockedWriter{ cs: cs, err: true },
+		Shell:  cs,
+		Env:    cs.Vars(),
+		Format: cs.Format,
+		color:  cs.colorEnabled(cs.Out),
+	}
+}
@@ -0,0 +1,93 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"bytes"
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestInvocation(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	var seen *shell.Invocation
+	cmd := &shell.Command{
+		Name:   "greet",
+		Flags:  shell.FlagOptionalArgs,
+		Invoke: func(inv *shell.Invocation) error {
+			seen = inv
+			input, err := ioutil.ReadAll(inv.Stdin)
+			if err != nil {
+				return err
+			}
+			inv.Printf("hello %s from %s\n", strings.Join(inv.Args, " "), inv.Getenv("who"))
+			inv.Println("read", string(input))
+			return nil
+		},
+	}
+	mode := &shell.CommandMode{ Name: "people", Commands: []*shell.Command{ cmd } }
+	cs := shell.NewCommandShell("# ", []*shell.Command{}, mode)
+	out := new(bytes.Buffer)
+	cs.Out = out
+	cs.In = strings.NewReader("some input")
+	cs.SetVar("who", "tests")
+	cs.Use(func(cmd *shell.Command, mode *shell.CommandMode, next shell.CommandHandler) shell.CommandHandler {
+		return func(args []string) error {
+			return next(append(args, "there"))
+		}
+	})
+
+	assert.Nil(cs.RunCommand([]string{ "greet", "world" }))
+	assert.Equal("hello world there from tests\nread some input\n", out.String())
+	assert.Equal(cs, seen.Shell)
+	assert.Equal(mode, seen.Mode)
+	assert.Equal(cmd, seen.Command)
+	assert.Equal([]string{ "world", "there" }, seen.Args)
+	assert.Equal("tests", seen.Env["who"])
+
+	// The variables are a snapshot taken when the command ran
+	cs.SetVar("who", "changed")
+	assert.Equal("tests", seen.Getenv("who"))
+}
+
+func TestInvocation_Streams(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	out := new(bytes.Buffer)
+	errOut := new(bytes.Buffer)
+	inv := &shell.Invocation{ Stdout: out, Stderr: errOut }
+
+	var printer shell.Printer = inv
+	printer.Printf("%d\n", 42)
+	printer.Println("a", "b")
+	assert.Equal("42\na b\n", out.String())
+
+	cs := shell.NewCommandShell("# ", []*shell.Command{
+		{
+			Name:       "old",
+			Deprecated: "use new instead",
+			Invoke:     func(inv *shell.Invocation) error { inv.Println("ran"); return nil },
+		},
+	})
+	out.Reset()
+	cs.Out = out
+	cs.Err = errOut
+	assert.Nil(cs.RunCommand([]string{ "old" }))
+	assert.Equal("ran\n", out.String())
+	assert.Equal("WARNING: old is deprecated, use new instead\n", errOut.String())
+}
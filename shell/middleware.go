@@ -0,0 +1,45 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+// Middleware wraps the next handler in the chain. It is given the resolved
+// command and the mode it was found in, and can short-circuit the command by
+// returning a handler which never calls next.
+type Middleware func(cmd *Command, mode *CommandMode, next CommandHandler) CommandHandler
+
+// Use adds middleware which runs around every command in the shell. Shell
+// middleware runs before the middleware of the command's mode, and within
+// each list the first one added is the outermost.
+func (cs *Shell) Use(mw ... Middleware) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.middleware = append(cs.middleware, mw...)
+}
+
+// chainMiddleware wraps the command in the middleware, the arguments the
+// innermost middleware passes on become the invocation's Args
+func chainMiddleware(cmd *Command, mode *CommandMode, inv *Invocation, chains ... []Middleware) CommandHandler {
+	handler := CommandHandler(func(args []string) error {
+		inv.Args = args
+		return cmd.invoke(inv)
+	})
+	for c := len(chains) - 1; c >= 0; c-- {
+		for i := len(chains[c]) - 1; i >= 0; i-- {
+			handler = chains[c][i](cmd, mode, handler)
+		}
+	}
+	return handler
+}
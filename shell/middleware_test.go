@@ -0,0 +1,87 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"errors"
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"testing"
+)
+
+func recordingMiddleware(name string, calls *[]string) shell.Middleware {
+	return func(cmd *shell.Command, mode *shell.CommandMode, next shell.CommandHandler) shell.CommandHandler {
+		return func(args []string) error {
+			*calls = append(*calls, name + ":" + mode.Name + ":" + cmd.Name)
+			return next(args)
+		}
+	}
+}
+
+func TestShell_Use(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	calls := make([]string, 0)
+	var gotArgs []string
+
+	mode := &shell.CommandMode{
+		Name:        "admin",
+		Commands:    []*shell.Command{
+			{
+				Name:    "dump",
+				Handler: func(args []string) error {
+					gotArgs = args
+					calls = append(calls, "handler")
+					return nil
+				},
+			},
+		},
+	}
+	mode.Use(recordingMiddleware("mode", &calls))
+
+	cs := shell.NewCommandShell("# ", []*shell.Command{
+		{ Name: "global-cmd", Handler: shell.NoOpHandler() },
+	}, mode)
+	cs.Use(recordingMiddleware("first", &calls), recordingMiddleware("second", &calls))
+
+	assert.Nil(cs.RunCommand([]string{ "dump", "a", "b" }))
+	assert.Equal([]string{ "a", "b" }, gotArgs)
+	assert.Equal([]string{ "first:admin:dump", "second:admin:dump", "mode:admin:dump", "handler" }, calls)
+
+	// Global commands don't pass through the admin middleware
+	calls = calls[:0]
+	assert.Nil(cs.RunCommand([]string{ "global-cmd" }))
+	assert.Equal([]string{ "first:global:global-cmd", "second:global:global-cmd" }, calls)
+}
+
+func TestShell_Use_ShortCircuit(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	denied := errors.New("denied")
+	called := false
+
+	cs := shell.NewCommandShell("# ", []*shell.Command{
+		{ Name: "secret", Handler: func(_ []string) error { called = true; return nil } },
+	})
+	cs.Use(func(cmd *shell.Command, _ *shell.CommandMode, next shell.CommandHandler) shell.CommandHandler {
+		if cmd.Name == "secret" {
+			return func(_ []string) error { return denied }
+		}
+		return next
+	})
+
+	assert.Equal(denied, cs.RunCommand([]string{ "secret" }))
+	assert.False(called)
+	assert.Nil(cs.RunCommand([]string{ "help" }))
+}
@@ -24,6 +24,10 @@ type CommandMode struct {
 	Description string
 	Commands []*Command
 	Delegate *CommandMode
+	Middleware []Middleware
+
+	// commands the shell adds to the mode, found after Commands
+	builtins []*Command
 }
 
 func HandlerWrap(op func()) CommandHandler {
@@ -37,24 +41,53 @@ func NoOpHandler() CommandHandler {
 	return func(_ []string) error { return nil }
 }
 
-func newGlobalMode(help CommandHandler, cmds []*Command) *CommandMode {
+func newGlobalMode(help InvocationHandler, cmds []*Command) *CommandMode {
+	helpMode := NewHelpMode(nil)
+	helpMode.Commands[0].Invoke = help
 	return &CommandMode{
 		Name:        "global",
 		Description: "Available commands",
 		Commands:    cmds,
-		Delegate:    NewHelpMode(help),
+		Delegate:    helpMode,
+		builtins:    append(newPipelineCommands(), newTextCommands()...),
 	}
 }
 
+// allCommands is the mode's commands followed by any the shell added
+func (cm *CommandMode) allCommands() []*Command {
+	if len(cm.builtins) == 0 {
+		return cm.Commands
+	}
+	return append(append([]*Command{}, cm.Commands...), cm.builtins...)
+}
+
 func (cm *CommandMode) Match(cmd string) (*Command, error) {
-	for _, c := range cm.Commands {
+	c, _, err := cm.Resolve(cmd)
+	return c, err
+}
+
+// Resolve works like Match but also returns the mode in the delegate
+// chain which the command was found in
+func (cm *CommandMode) Resolve(cmd string) (*Command, *CommandMode, error) {
+	commands := cm.allCommands()
+	for _, c := range commands {
 		if strings.Compare(c.Name, cmd) == 0 {
-			return c, nil
+			return c, cm, nil
+		}
+	}
+	for _, c := range commands {
+		if c.Matches(cmd) {
+			return c, cm, nil
 		}
 	}
 	if cm.Delegate != nil {
-		return cm.Delegate.Match(cmd)
+		return cm.Delegate.Resolve(cmd)
 	} else {
-		return nil, ErrNoMatch
+		return nil, nil, ErrNoMatch
 	}
 }
+
+// Use adds middleware which runs around every command defined in this mode
+func (cm *CommandMode) Use(mw ... Middleware) {
+	cm.Middleware = append(cm.Middleware, mw...)
+}
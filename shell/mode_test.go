@@ -112,3 +112,25 @@ func TestCommandMode_Match_WithDelegate(t *testing.T) {
 	_, err := cm.Match("not-gonna-be-there")
 	assert.Equal(shell.ErrNoMatch, err)
 }
+
+func TestCommandMode_Resolve(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cm := createTestCommandMode(1)
+	cm.Delegate = createTestCommandMode(3)
+	cm.Delegate.Name = "the-delegate"
+
+	cmd, mode, err := cm.Resolve("cmd-0")
+	assert.Nil(err)
+	assert.Equal(cm.Commands[0], cmd)
+	assert.Equal(cm, mode)
+
+	cmd, mode, err = cm.Resolve("cmd-2")
+	assert.Nil(err)
+	assert.Equal(cm.Delegate.Commands[2], cmd)
+	assert.Equal(cm.Delegate, mode)
+
+	cmd, mode, err = cm.Resolve("nope")
+	assert.Nil(cmd)
+	assert.Nil(mode)
+	assert.Equal(shell.ErrNoMatch, err)
+}
@@ -0,0 +1,76 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"github.com/threeguys/golang-ezshell/terminal"
+	"log"
+	"os"
+)
+
+// pagerWriter sends a command's output through the pager, which writes it
+// out as it comes until it fills the screen and pages it after that
+type pagerWriter struct {
+	cs *Shell
+	pager *terminal.Pager
+}
+
+func (pw *pagerWriter) Write(data []byte) (int, error) {
+	pw.cs.outLock.Lock()
+	defer pw.cs.outLock.Unlock()
+	return pw.pager.Write(data)
+}
+
+// terminalInput returns the shell's input when both it and the output are
+// terminals, which is when the pager can be used
+func (cs *Shell) terminalInput() (*os.File, bool) {
+	in, ok := cs.In.(*os.File)
+	if !ok || !terminal.IsTerminal(in.Fd()) {
+		return nil, false
+	}
+	if fd, ok := terminal.Fd(cs.Out); !ok || !terminal.IsTerminal(fd) {
+		return nil, false
+	}
+	return in, true
+}
+
+// startPager swaps the invocation's output for a pagerWriter when the
+// command is writing straight to a terminal, unless paging is turned off
+// for the shell or the command
+func (cs *Shell) startPager(cmd *Command, inv *Invocation) *pagerWriter {
+	if cs.NoPager || cmd.NoPager {
+		return nil
+	} else if lw, ok := inv.Stdout.(lockedWriter); !ok || lw.err {
+		return nil
+	}
+	in, ok := cs.terminalInput()
+	if !ok {
+		return nil
+	}
+	pw := &pagerWriter{ cs: cs, pager: terminal.NewTerminalPager(in, cs.Out) }
+	inv.Stdout = pw
+	return pw
+}
+
+// finishPager lets the user page through the rest of the command's output
+// when it didn't fit on the screen. Other output waits until they quit.
+func (cs *Shell) finishPager(pw *pagerWriter) {
+	cs.outLock.Lock()
+	defer cs.outLock.Unlock()
+	if err := pw.pager.Close(); err != nil {
+		log.Println("Unable to page output", err)
+	}
+}
@@ -0,0 +1,36 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"github.com/threeguys/golang-toolkit/objects"
+	"strings"
+	"testing"
+)
+
+func TestShell_Pager_NotTerminal(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+
+	// Output which isn't going to a terminal is never paged
+	out, err := runPipelineScript("help\n")
+	assert.Nil(err)
+	assert.True(strings.Count(out, "\n") > 24)
+
+	// Help goes through the invocation, so it can be filtered too
+	out, err = runPipelineScript("help | grep -- '---> Pipeline'\nhelp where | head -1\n")
+	assert.Nil(err)
+	assert.Equal("    ---> Pipeline <---\nUsage: where <field> <op> <value>\n", out)
+}
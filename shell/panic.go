@@ -0,0 +1,61 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned in place of a panic raised by a command handler
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", pe.Value)
+}
+
+// runRecovered runs the pipeline, converting any panic into a PanicError so
+// the session survives a bad handler
+func (cs *Shell) runRecovered(stages ... []string) error {
+	return recovered(func() error { return cs.RunPipeline(stages...) })
+}
+
+func recovered(run func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{ Value: r, Stack: debug.Stack() }
+		}
+	}()
+	return run()
+}
+
+// printError reports a failed command, panics only show their stack
+// when Debug is set and a StatusError isn't shown at all
+func (cs *Shell) printError(err error) {
+	var se StatusError
+	if errors.As(err, &se) {
+		return
+	}
+	msg := cs.themeFor(cs.Err).Error.Apply(fmt.Sprint("ERROR: ", err)) + "\n"
+	if pe, ok := err.(*PanicError); ok && cs.Debug {
+		msg += fmt.Sprintf("%s\n", pe.Stack)
+	}
+	cs.writeErr(msg)
+}
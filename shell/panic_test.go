@@ -0,0 +1,82 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"strings"
+	"testing"
+)
+
+func createPanicShell() *shell.Shell {
+	cs := shell.NewCommandShell("# ", []*shell.Command{
+		{
+			Name:    "boom",
+			Handler: func(_ []string) error {
+				var cmd *shell.Command
+				return cmd.Run(nil)
+			},
+		},
+		{ Name: "noop", Handler: shell.NoOpHandler() },
+	})
+	cs.Quiet = true
+	return cs
+}
+
+func TestPanicError(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	pe := &shell.PanicError{ Value: "oh no" }
+	assert.Equal("panic: oh no", pe.Error())
+}
+
+func TestShell_RunSupplier_Panic(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	calls := 0
+	cs := createPanicShell()
+	cs.Use(func(cmd *shell.Command, _ *shell.CommandMode, next shell.CommandHandler) shell.CommandHandler {
+		return func(args []string) error {
+			calls++
+			return next(args)
+		}
+	})
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+	cs.Err = out
+
+	_ = cs.RunSupplier(shell.NewListCommandSupplier([]string{ "boom" }, []string{ "noop" }))
+	assert.Equal(2, calls)
+
+	logs := getLogData(t, out)
+	assert.True(strings.HasPrefix(logs, "ERROR: panic: runtime error: invalid memory address"))
+	assert.False(strings.Contains(logs, "goroutine"))
+}
+
+func TestShell_RunSupplier_PanicDebug(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createPanicShell()
+	cs.Debug = true
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+	cs.Err = out
+
+	_ = cs.RunSupplier(shell.NewListCommandSupplier([]string{ "boom" }))
+	logs := getLogData(t, out)
+	assert.True(strings.HasPrefix(logs, "ERROR: panic: "))
+	assert.True(strings.Contains(logs, "goroutine"))
+}
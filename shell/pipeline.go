@@ -0,0 +1,417 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/threeguys/golang-ezshell/parser"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoRecords = errors.New("expects records from a pipeline")
+
+	whereExpr = regexp.MustCompile(`^\s*([\w.]+)\s*(==|!=|<=|>=|=~|!~|=|<|>)\s*(.*?)\s*$`)
+)
+
+// PipelineSupplier is implemented by suppliers which can read a line with '|'
+// kept as an operator. Lines from these suppliers can be pipelines even with
+// scripting off, unless the shell's NoPipes is set.
+type PipelineSupplier interface {
+	CommandSupplier
+	ReadPipeline() ([]parser.Token, error)
+}
+
+// RunPipeline runs the commands in order, each one gets the output of the
+// one before as its Stdin. Records emitted by a command are also passed on
+// as the next one's Input, only the last command's records are rendered.
+// The pipeline stops at the first command which fails.
+func (cs *Shell) RunPipeline(stages ... []string) error {
+	runs := make([]stage, 0, len(stages))
+	for _, parsed := range stages {
+		parsed := parsed
+		runs = append(runs, func(inv *Invocation) error { return cs.invoke(parsed, inv) })
+	}
+	return cs.runStages(runs)
+}
+
+// stage runs one part of a pipeline with the invocation's streams
+type stage func(inv *Invocation) error
+
+func (cs *Shell) runStages(stages []stage) error {
+	var prev *Invocation
+	var prevOut *bytes.Buffer
+	for i, run := range stages {
+		inv := cs.newInvocation()
+		if prev != nil {
+			inv.Stdin = bytes.NewReader(prevOut.Bytes())
+			inv.input, inv.hasInput = prev.records, prev.emitted
+			inv.Format = prev.Format
+			inv.piped = true
+		}
+
+		var out *bytes.Buffer
+		if i < len(stages)-1 {
+			out = new(bytes.Buffer)
+			inv.Stdout = out
+			inv.pipe = true
+			inv.color = false
+		}
+
+		if err := run(inv); err != nil {
+			return err
+		}
+		prev, prevOut = inv, out
+	}
+	return nil
+}
+
+// newPipelineCommands creates the built-in commands which work on the
+// records passed along a pipeline
+func newPipelineCommands() []*Command {
+	return []*Command{
+		{
+			Name:        "where",
+			Description: "keep the records where the field matches, ops are = != < <= > >= =~ !~",
+			Category:    "Pipeline",
+			Flags:       FlagRequiresArgs,
+			Invoke:      builtinWhere,
+			Arguments:   []*Argument{
+				{ Name: "<field> <op> <value>", Description: "fields can be nested with dots, =~ matches a regular expression" },
+			},
+			Examples:    []string{ "hosts | where up = true", "files | where size>1024" },
+		},
+		{
+			Name:        "select",
+			Description: "keep only the named fields of each record",
+			Category:    "Pipeline",
+			Flags:       FlagRequiresArgs,
+			Invoke:      builtinSelect,
+			Arguments:   []*Argument{
+				{ Name: "<field>...", Description: "the fields to keep, in order" },
+			},
+		},
+		{
+			Name:        "sort",
+			Description: "sort the records by the fields, or the lines of text",
+			Category:    "Pipeline",
+			Flags:       FlagOptionalArgs,
+			Invoke:      builtinSort,
+			Arguments:   []*Argument{
+				{ Name: "[-r]", Description: "sort in descending order" },
+				{ Name: "[-n]", Description: "sort lines by their leading number" },
+				{ Name: "<field>...", Description: "the fields to sort records by, the whole record if none" },
+			},
+		},
+		{
+			Name:        "head",
+			Description: "keep the first n records or lines, 10 by default",
+			Category:    "Pipeline",
+			Flags:       FlagOptionalArgs,
+			Invoke:      builtinHead,
+			Arguments:   []*Argument{ { Name: "[-n] [n]", Description: "how many to keep" } },
+		},
+		{
+			Name:        "tail",
+			Description: "keep the last n records or lines, 10 by default",
+			Category:    "Pipeline",
+			Flags:       FlagOptionalArgs,
+			Invoke:      builtinTail,
+			Arguments:   []*Argument{ { Name: "[-n] [n]", Description: "how many to keep" } },
+		},
+		{
+			Name:        "count",
+			Description: "count the records",
+			Category:    "Pipeline",
+			Invoke:      builtinCount,
+		},
+		{
+			Name:        "uniq",
+			Description: "drop records which repeat the fields of an earlier one",
+			Category:    "Pipeline",
+			Flags:       FlagOptionalArgs,
+			Invoke:      builtinUniq,
+			Arguments:   []*Argument{
+				{ Name: "<field>...", Description: "the fields to compare, the whole record if none" },
+			},
+		},
+	}
+}
+
+// pipelineInput returns the records piped into the command
+func pipelineInput(inv *Invocation) ([]interface{}, error) {
+	if records, ok := inv.Input(); ok {
+		return records, nil
+	}
+	return nil, fmt.Errorf("%s %w", inv.Command.Name, ErrNoRecords)
+}
+
+// FieldValue looks up a field of a struct or map record, a path with dots
+// looks into nested records. Names match the json field names ignoring case.
+func FieldValue(record interface{}, path string) (interface{}, bool) {
+	value := record
+	for _, name := range strings.Split(path, ".") {
+		names, values, ok := recordFields(value)
+		if !ok {
+			return nil, false
+		}
+		found := false
+		for _, n := range names {
+			if strings.EqualFold(n, name) {
+				value, found = values[n], true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// compareStrings compares numerically when both are numbers
+func compareStrings(a string, b string) int {
+	if x, err := strconv.ParseFloat(a, 64); err == nil {
+		if y, err := strconv.ParseFloat(b, 64); err == nil {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// recordKey is the text of the fields used to compare records, the whole
+// record is used when no fields are given
+func recordKey(record interface{}, fields []string) []string {
+	if len(fields) == 0 {
+		_, rows := tabulate([]interface{}{ record })
+		return rows[0]
+	}
+	key := make([]string, len(fields))
+	for i, f := range fields {
+		if value, ok := FieldValue(record, f); ok {
+			key[i] = cellString(value)
+		}
+	}
+	return key
+}
+
+func builtinWhere(inv *Invocation) error {
+	records, err := pipelineInput(inv)
+	if err != nil {
+		return err
+	}
+
+	match := whereExpr.FindStringSubmatch(strings.Join(inv.Args, " "))
+	if match == nil {
+		return fmt.Errorf("invalid condition [%s], expected <field> <op> <value>", strings.Join(inv.Args, " "))
+	}
+	field, op, want := match[1], match[2], match[3]
+
+	var re *regexp.Regexp
+	if op == "=~" || op == "!~" {
+		if re, err = regexp.Compile(want); err != nil {
+			return err
+		}
+	}
+
+	kept := make([]interface{}, 0)
+	for _, record := range records {
+		value, ok := FieldValue(record, field)
+		if !ok {
+			continue
+		}
+		text := cellString(value)
+		cmp := compareStrings(text, want)
+		keep := false
+		switch op {
+		case "=", "==":
+			keep = cmp == 0
+		case "!=":
+			keep = cmp != 0
+		case "<":
+			keep = cmp < 0
+		case "<=":
+			keep = cmp <= 0
+		case ">":
+			keep = cmp > 0
+		case ">=":
+			keep = cmp >= 0
+		case "=~":
+			keep = re.MatchString(text)
+		case "!~":
+			keep = !re.MatchString(text)
+		}
+		if keep {
+			kept = append(kept, record)
+		}
+	}
+	inv.Emit(kept)
+	return nil
+}
+
+func builtinSelect(inv *Invocation) error {
+	records, err := pipelineInput(inv)
+	if err != nil {
+		return err
+	}
+	selected := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		sel := &selectedRecord{ names: inv.Args, values: make(map[string]interface{}) }
+		for _, f := range inv.Args {
+			sel.values[f], _ = FieldValue(record, f)
+		}
+		selected = append(selected, sel)
+	}
+	inv.Emit(selected)
+	return nil
+}
+
+// builtinSort sorts records by their fields, or lines of text when it
+// wasn't given records
+func builtinSort(inv *Invocation) error {
+	flags, fields, err := parseFlags(inv.Args, "rn", "")
+	if err != nil {
+		return err
+	}
+	_, reverse := flags['r']
+	records, ok := inv.Input()
+	if !ok {
+		lines, err := readLines(inv)
+		if err != nil {
+			return err
+		}
+		_, numeric := flags['n']
+		sortLines(lines, numeric, reverse)
+		printLines(inv, lines)
+		return nil
+	}
+
+	keys := make([][]string, len(records))
+	order := make([]int, len(records))
+	for i, record := range records {
+		keys[i] = recordKey(record, fields)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ka, kb := keys[order[a]], keys[order[b]]
+		for i := range ka {
+			if cmp := compareStrings(ka[i], kb[i]); cmp != 0 {
+				return (cmp < 0) != reverse
+			}
+		}
+		return false
+	})
+
+	sorted := make([]interface{}, len(records))
+	for i, o := range order {
+		sorted[i] = records[o]
+	}
+	inv.Emit(sorted)
+	return nil
+}
+
+// countArg is the optional count given to head and tail, as n, -n or -n n
+func countArg(args []string) (int, error) {
+	if len(args) == 0 {
+		return 10, nil
+	} else if args[0] == "-n" && len(args) > 1 {
+		args = args[1:]
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(args[0], "-"))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid count [%s]", args[0])
+	}
+	return n, nil
+}
+
+// keepRange runs head or tail, on records when it was given them and on
+// lines of text otherwise
+func keepRange(inv *Invocation, last bool) error {
+	n, err := countArg(inv.Args)
+	if err != nil {
+		return err
+	}
+
+	records, ok := inv.Input()
+	if !ok {
+		lines, err := readLines(inv)
+		if err != nil {
+			return err
+		}
+		if n < len(lines) && last {
+			lines = lines[len(lines)-n:]
+		} else if n < len(lines) {
+			lines = lines[:n]
+		}
+		printLines(inv, lines)
+		return nil
+	}
+
+	if n < len(records) && last {
+		records = records[len(records)-n:]
+	} else if n < len(records) {
+		records = records[:n]
+	}
+	inv.Emit(records)
+	return nil
+}
+
+func builtinHead(inv *Invocation) error {
+	return keepRange(inv, false)
+}
+
+func builtinTail(inv *Invocation) error {
+	return keepRange(inv, true)
+}
+
+func builtinCount(inv *Invocation) error {
+	records, err := pipelineInput(inv)
+	if err != nil {
+		return err
+	}
+	inv.Emit(len(records))
+	return nil
+}
+
+func builtinUniq(inv *Invocation) error {
+	records, err := pipelineInput(inv)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	kept := make([]interface{}, 0)
+	for _, record := range records {
+		key := strings.Join(recordKey(record, inv.Args), "\x00")
+		if !seen[key] {
+			seen[key] = true
+			kept = append(kept, record)
+		}
+	}
+	inv.Emit(kept)
+	return nil
+}
@@ -0,0 +1,165 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+type pipelineHost struct {
+	Name string            `json:"name"`
+	Up   bool              `json:"up"`
+	Load float64           `json:"load"`
+	Tags map[string]string `json:"tags"`
+}
+
+func createPipelineShell() (*shell.Shell, *bytes.Buffer) {
+	cs := shell.NewCommandShell("# ", []*shell.Command{
+		{
+			Name:   "hosts",
+			Invoke: func(inv *shell.Invocation) error {
+				inv.Emit([]pipelineHost{
+					{ Name: "web1", Up: true, Load: 0.5, Tags: map[string]string{ "role": "web" } },
+					{ Name: "db1", Up: false, Load: 12, Tags: map[string]string{ "role": "db" } },
+					{ Name: "web2", Up: true, Load: 2.25, Tags: map[string]string{ "role": "web" } },
+				})
+				return nil
+			},
+		},
+		{
+			Name:   "lines",
+			Invoke: func(inv *shell.Invocation) error {
+				data, err := ioutil.ReadAll(inv.Stdin)
+				if err != nil {
+					return err
+				}
+				_, ok := inv.Input()
+				inv.Printf("records=%v lines=%d\n", ok, strings.Count(string(data), "\n"))
+				return nil
+			},
+		},
+	})
+	cs.Quiet = true
+	cs.Scripting = true
+	out := new(bytes.Buffer)
+	cs.Out = out
+	cs.Err = out
+	return cs, out
+}
+
+func runPipelineScript(script string) (string, error) {
+	cs, out := createPipelineShell()
+	cs.In = strings.NewReader(script)
+	err := cs.Run()
+	return out.String(), err
+}
+
+func TestFieldValue(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	host := pipelineHost{ Name: "web1", Tags: map[string]string{ "role": "web" } }
+
+	value, ok := shell.FieldValue(host, "NAME")
+	assert.True(ok)
+	assert.Equal("web1", value)
+	value, ok = shell.FieldValue(&host, "tags.role")
+	assert.True(ok)
+	assert.Equal("web", value)
+	_, ok = shell.FieldValue(host, "tags.missing")
+	assert.False(ok)
+	_, ok = shell.FieldValue("scalar", "name")
+	assert.False(ok)
+}
+
+func TestPipeline_Filters(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+
+	out, err := runPipelineScript(`
+hosts | where up = true | select name load
+hosts | where load>=2 | sort -r name | select name --output json
+hosts | where tags.role =~ ^d | count
+hosts | sort load | head 1 | select name
+hosts | tail -1 | select name
+hosts | uniq up | count
+hosts | uniq | count
+hosts | head 2 | select name load --output yaml
+`)
+	assert.Nil(err)
+	assert.Equal(
+		"NAME  LOAD\nweb1  0.5\nweb2  2.25\n" +
+		"[\n  {\n    \"name\": \"web2\"\n  },\n  {\n    \"name\": \"db1\"\n  }\n]\n" +
+		"1\n" +
+		"NAME\nweb1\n" +
+		"NAME\nweb2\n" +
+		"2\n" +
+		"3\n" +
+		"- name: web1\n  load: 0.5\n- name: db1\n  load: 12\n", out)
+}
+
+func TestPipeline_Text(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+
+	// Text commands see the records rendered in the format of the command
+	// which emitted them
+	out, err := runPipelineScript("hosts | lines\nhosts --output json | select name | lines\nlines | lines\n")
+	assert.Nil(err)
+	assert.Equal("records=true lines=4\nrecords=true lines=11\nrecords=false lines=1\n", out)
+}
+
+func TestPipeline_PrintfHandler(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs, out := createPipelineShell()
+	assert.Nil(cs.AddCommand("global", &shell.Command{
+		Name:    "dump",
+		Handler: func(_ []string) error {
+			cs.Printf("PATH=%s\n", "/usr/bin")
+			cs.Println("HOME=/home/user")
+			return nil
+		},
+	}))
+
+	// Handlers printing through the shell are piped like any other
+	cs.In = strings.NewReader("dump | grep PATH\ndump | wc -l\ndump | lines | grep -v HOME\ndump\n")
+	assert.Nil(cs.Run())
+	assert.Equal("PATH=/usr/bin\n2\nrecords=false lines=2\nPATH=/usr/bin\nHOME=/home/user\n", out.String())
+}
+
+func TestPipeline_Errors(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+
+	out, err := runPipelineScript("lines | where up = true\nhosts | where up\nhosts | bogus | lines\n")
+	assert.Nil(err)
+	assert.Equal(
+		"ERROR: where expects records from a pipeline\n" +
+		"ERROR: invalid condition [up], expected <field> <op> <value>\n" +
+		"ERROR: no matching command found\n", out)
+
+	out, err = runPipelineScript("set -e\nhosts | | lines\n")
+	assert.True(errors.Is(err, shell.ErrSyntax))
+
+	// A function's output is passed on as text
+	out, err = runPipelineScript("function f { hosts; }\nf | lines\n")
+	assert.Nil(err)
+	assert.Equal("records=false lines=4\n", out)
+
+	cs, _ := createPipelineShell()
+	assert.True(errors.Is(cs.RunCommand([]string{ "count" }), shell.ErrNoRecords))
+}
@@ -0,0 +1,59 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorPolicy controls what RunSupplier does when a command fails
+type ErrorPolicy int
+
+const (
+	ContinueOnError ErrorPolicy = iota // print the error and keep going
+	StopOnError                        // stop at the first error
+	StopAfterErrors                    // stop once Shell.MaxErrors errors have happened
+)
+
+// ScriptError is returned by RunSupplier when the error policy stops it,
+// identifying the line and command which failed
+type ScriptError struct {
+	Line int
+	Command []string
+	Err error
+}
+
+func (se *ScriptError) Error() string {
+	return fmt.Sprintf("line %d: %s: %v", se.Line, strings.Join(se.Command, " "), se.Err)
+}
+
+func (se *ScriptError) Unwrap() error {
+	return se.Err
+}
+
+func (cs *Shell) shouldStop(errCount int) bool {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	switch cs.ErrorPolicy {
+	case StopOnError:
+		return true
+	case StopAfterErrors:
+		return errCount >= cs.MaxErrors
+	default:
+		return false
+	}
+}
@@ -0,0 +1,93 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"errors"
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"io"
+	"strings"
+	"testing"
+)
+
+func writeScript(lines ... string) *strings.Reader {
+	return strings.NewReader(strings.Join(lines, "\n") + "\n")
+}
+
+func runPolicyScript(t *testing.T, cs *shell.Shell, lines ... string) error {
+	assert := objects.NewTestAssertions(t)
+	in := makeTempLog(t)
+	defer func() { assert.Nil(in.Close()) }()
+	_, err := io.Copy(in, writeScript(lines...))
+	assert.Nil(err)
+	resetTempFile(t, in)
+
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+	return cs.RunFile(in)
+}
+
+func TestScriptError(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	se := &shell.ScriptError{ Line: 3, Command: []string{ "err", "arg" }, Err: mockErrMade }
+	assert.Equal("line 3: err arg: i made an error", se.Error())
+	assert.True(errors.Is(se, mockErrMade))
+}
+
+func TestShell_ErrorPolicy_Continue(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createMockTestShell()
+	assert.Nil(runPolicyScript(t, cs, "err", "noop", "err"))
+}
+
+func TestShell_ErrorPolicy_Stop(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createMockTestShell()
+	cs.ErrorPolicy = shell.StopOnError
+
+	err := runPolicyScript(t, cs, "noop", "err first", "noop")
+	se, ok := err.(*shell.ScriptError)
+	assert.True(ok)
+	assert.Equal(2, se.Line)
+	assert.Equal([]string{ "err", "first" }, se.Command)
+	assert.Equal(mockErrMade, se.Err)
+}
+
+func TestShell_ErrorPolicy_StopAfter(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createMockTestShell()
+	cs.ErrorPolicy = shell.StopAfterErrors
+	cs.MaxErrors = 2
+
+	err := runPolicyScript(t, cs, "err", "noop", "missing", "err")
+	se, ok := err.(*shell.ScriptError)
+	assert.True(ok)
+	assert.Equal(3, se.Line)
+	assert.Equal(shell.ErrNoMatch, se.Err)
+}
+
+func TestShell_ErrorPolicy_SetE(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createMockTestShell()
+
+	err := runPolicyScript(t, cs, "err", "set -e", "noop", "err", "noop")
+	se, ok := err.(*shell.ScriptError)
+	assert.True(ok)
+	assert.Equal(4, se.Line)
+	assert.Equal(shell.StopOnError, cs.ErrorPolicy)
+}
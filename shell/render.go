@@ -0,0 +1,467 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// OutputFormat selects how records emitted by handlers are rendered
+type OutputFormat int
+
+const (
+	FormatTable OutputFormat = iota
+	FormatJSON
+	FormatYAML
+	FormatCSV
+)
+
+const (
+	OutputOption = "--output"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown output format")
+
+	formatNames = []string{ "table", "json", "yaml", "csv" }
+)
+
+func (f OutputFormat) String() string {
+	if int(f) >= 0 && int(f) < len(formatNames) {
+		return formatNames[f]
+	}
+	return fmt.Sprintf("format(%d)", int(f))
+}
+
+// ParseFormat returns the format with the name, as given to --output
+func ParseFormat(name string) (OutputFormat, error) {
+	for i, n := range formatNames {
+		if strings.EqualFold(n, name) {
+			return OutputFormat(i), nil
+		}
+	}
+	return FormatTable, fmt.Errorf("%w [%s]", ErrUnknownFormat, name)
+}
+
+// outputOption removes a --output <format> or --output=<format> option from
+// the arguments. Values which aren't a format are left for the handler.
+func outputOption(args []string) ([]string, OutputFormat, bool) {
+	for i, arg := range args {
+		if arg == OutputOption && i+1 < len(args) {
+			if format, err := ParseFormat(args[i+1]); err == nil {
+				return append(append([]string{}, args[:i]...), args[i+2:]...), format, true
+			}
+		} else if strings.HasPrefix(arg, OutputOption + "=") {
+			if format, err := ParseFormat(arg[len(OutputOption)+1:]); err == nil {
+				return append(append([]string{}, args[:i]...), args[i+1:]...), format, true
+			}
+		}
+	}
+	return args, FormatTable, false
+}
+
+// Render writes the records in the format. Records are usually structs or
+// maps with string keys, anything else is rendered as a single value.
+func Render(w io.Writer, format OutputFormat, records []interface{}) error {
+	return render(w, format, records, Theme{})
+}
+
+// render works like Render, styling the table header with the theme
+func render(w io.Writer, format OutputFormat, records []interface{}, theme Theme) error {
+	switch format {
+	case FormatTable:
+		return renderTable(w, records, theme.TableHeader)
+	case FormatJSON:
+		if records == nil {
+			records = []interface{}{}
+		}
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case FormatYAML:
+		_, err := io.WriteString(w, renderYAML(records))
+		return err
+	case FormatCSV:
+		return renderCSV(w, records)
+	default:
+		return fmt.Errorf("%w [%s]", ErrUnknownFormat, format)
+	}
+}
+
+// flatten turns the values given to Emit into records, slices contribute
+// each of their elements
+func flatten(values []interface{}) []interface{} {
+	records := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		v := reflect.ValueOf(value)
+		if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < v.Len(); i++ {
+				records = append(records, v.Index(i).Interface())
+			}
+		} else {
+			records = append(records, value)
+		}
+	}
+	return records
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+func isTextMarshaler(v reflect.Value) bool {
+	if !v.IsValid() || !v.CanInterface() {
+		return false
+	}
+	_, ok := v.Interface().(encoding.TextMarshaler)
+	return ok
+}
+
+// fieldName is the json name of a struct field, or empty to skip it
+func fieldName(f reflect.StructField) string {
+	if len(f.PkgPath) > 0 {
+		return ""
+	}
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	} else if len(name) > 0 {
+		return name
+	}
+	return f.Name
+}
+
+// selectedRecord is a record whose fields keep the order they were given
+// in, select creates them
+type selectedRecord struct {
+	names []string
+	values map[string]interface{}
+}
+
+func (sr *selectedRecord) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range sr.names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(sr.values[name])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// recordFields returns the field names and values of a struct or a map with
+// string keys, in the order they should be shown
+func recordFields(record interface{}) ([]string, map[string]interface{}, bool) {
+	switch sr := record.(type) {
+	case *selectedRecord:
+		return sr.names, sr.values, true
+	case selectedRecord:
+		return sr.names, sr.values, true
+	}
+	v := indirect(reflect.ValueOf(record))
+	if !v.IsValid() || isTextMarshaler(v) {
+		return nil, nil, false
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		names := make([]string, 0, v.NumField())
+		values := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			if name := fieldName(v.Type().Field(i)); len(name) > 0 {
+				names = append(names, name)
+				values[name] = v.Field(i).Interface()
+			}
+		}
+		return names, values, true
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, nil, false
+		}
+		names := make([]string, 0, v.Len())
+		values := make(map[string]interface{})
+		for _, key := range v.MapKeys() {
+			names = append(names, key.String())
+			values[key.String()] = v.MapIndex(key).Interface()
+		}
+		sort.Strings(names)
+		return names, values, true
+
+	default:
+		return nil, nil, false
+	}
+}
+
+// tabulate lays the records out as rows, the columns are every field seen
+// in the order they were first seen. Records which aren't structs or maps
+// have a single value column, nil columns means there were only those.
+func tabulate(records []interface{}) ([]string, [][]string) {
+	columns := make([]string, 0)
+	seen := make(map[string]bool)
+	fields := make([]map[string]interface{}, len(records))
+	hasFields := false
+
+	for i, record := range records {
+		names, values, ok := recordFields(record)
+		if !ok {
+			names = []string{ "value" }
+			values = map[string]interface{}{ "value": record }
+		} else {
+			hasFields = true
+		}
+		fields[i] = values
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				columns = append(columns, name)
+			}
+		}
+	}
+
+	rows := make([][]string, len(records))
+	for i := range records {
+		rows[i] = make([]string, len(columns))
+		for c, name := range columns {
+			if value, ok := fields[i][name]; ok {
+				rows[i][c] = cellString(value)
+			}
+		}
+	}
+
+	if !hasFields {
+		return nil, rows
+	}
+	return columns, rows
+}
+
+func cellString(value interface{}) string {
+	v := indirect(reflect.ValueOf(value))
+	if !v.IsValid() || ((v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()) {
+		return ""
+	}
+	return strings.ReplaceAll(fmt.Sprint(v.Interface()), "\n", " ")
+}
+
+func renderTable(w io.Writer, records []interface{}, headerStyle Style) error {
+	columns, rows := tabulate(records)
+	var sb strings.Builder
+	if columns == nil {
+		for _, row := range rows {
+			sb.WriteString(row[0] + "\n")
+		}
+		_, err := io.WriteString(w, sb.String())
+		return err
+	}
+
+	header := make([]string, len(columns))
+	widths := make([]int, len(columns))
+	for c, name := range columns {
+		header[c] = strings.ToUpper(name)
+		widths[c] = utf8.RuneCountInString(header[c])
+	}
+	for _, row := range rows {
+		for c, cell := range row {
+			if n := utf8.RuneCountInString(cell); n > widths[c] {
+				widths[c] = n
+			}
+		}
+	}
+
+	formatRow := func(row []string) string {
+		line := ""
+		for c, cell := range row {
+			line += cell
+			if c < len(row)-1 {
+				line += strings.Repeat(" ", widths[c] - utf8.RuneCountInString(cell) + 2)
+			}
+		}
+		return strings.TrimRight(line, " ")
+	}
+
+	sb.WriteString(headerStyle.Apply(formatRow(header)) + "\n")
+	for _, row := range rows {
+		sb.WriteString(formatRow(row) + "\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func renderCSV(w io.Writer, records []interface{}) error {
+	columns, rows := tabulate(records)
+	cw := csv.NewWriter(w)
+	if columns != nil {
+		if err := cw.Write(columns); err != nil {
+			return err
+		}
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// renderYAML writes the records as a YAML sequence, without needing a YAML
+// library. Field order and names follow the json encoding.
+func renderYAML(records []interface{}) string {
+	if len(records) == 0 {
+		return "[]\n"
+	}
+	var sb strings.Builder
+	yamlSequence(&sb, reflect.ValueOf(records), "")
+	return sb.String()
+}
+
+func yamlSequence(sb *strings.Builder, v reflect.Value, indent string) {
+	for i := 0; i < v.Len(); i++ {
+		item := indirect(v.Index(i))
+		if names, values, ok := recordFields(item.Interface()); ok && len(names) > 0 {
+			yamlMapping(sb, names, values, indent + "  ", indent + "- ")
+		} else {
+			sb.WriteString(indent + "-")
+			yamlValue(sb, item, indent + "  ")
+		}
+	}
+}
+
+// yamlMapping writes the fields, the first line starts with first so a
+// mapping can begin on the same line as its "- "
+func yamlMapping(sb *strings.Builder, names []string, values map[string]interface{}, indent string, first string) {
+	for i, name := range names {
+		if i == 0 {
+			sb.WriteString(first)
+		} else {
+			sb.WriteString(indent)
+		}
+		sb.WriteString(yamlString(name) + ":")
+		yamlValue(sb, reflect.ValueOf(values[name]), indent)
+	}
+}
+
+// yamlValue writes the value following a "key:" or "-" already written
+func yamlValue(sb *strings.Builder, v reflect.Value, indent string) {
+	v = indirect(v)
+	if scalar, ok := yamlScalar(v); ok {
+		sb.WriteString(" " + scalar + "\n")
+		return
+	}
+
+	if names, values, ok := recordFields(v.Interface()); ok {
+		if len(names) == 0 {
+			sb.WriteString(" {}\n")
+		} else {
+			sb.WriteString("\n")
+			yamlMapping(sb, names, values, indent + "  ", indent + "  ")
+		}
+	} else if v.Len() == 0 {
+		sb.WriteString(" []\n")
+	} else {
+		sb.WriteString("\n")
+		yamlSequence(sb, v, indent + "  ")
+	}
+}
+
+func yamlScalar(v reflect.Value) (string, bool) {
+	if !v.IsValid() {
+		return "null", true
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return "null", true
+		}
+	}
+
+	if isTextMarshaler(v) {
+		if text, err := v.Interface().(encoding.TextMarshaler).MarshalText(); err == nil {
+			return yamlString(string(text)), true
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface()), true
+	case reflect.String:
+		return yamlString(v.String()), true
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return yamlString(string(v.Bytes())), true
+		}
+		return "", false
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return yamlString(fmt.Sprint(v.Interface())), true
+		}
+		return "", false
+	case reflect.Struct, reflect.Array:
+		return "", false
+	default:
+		return yamlString(fmt.Sprint(v.Interface())), true
+	}
+}
+
+// yamlString quotes the string unless it is safe to write plain, which
+// rules out anything YAML would read as another type or as syntax
+func yamlString(s string) string {
+	if len(s) == 0 || strings.TrimSpace(s) != s || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return strconv.Quote(s)
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f {
+			return strconv.Quote(s)
+		}
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	return s
+}
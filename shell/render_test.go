@@ -0,0 +1,163 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"testing"
+)
+
+type renderFile struct {
+	Name    string            `json:"name"`
+	Size    int               `json:"size"`
+	Tags    []string          `json:"tags,omitempty"`
+	Labels  map[string]string `json:"labels"`
+	private int
+}
+
+func renderFiles() []renderFile {
+	return []renderFile{
+		{ Name: "a.txt", Size: 12, Tags: []string{ "x", "y z" }, Labels: map[string]string{ "owner": "me" } },
+		{ Name: "b long.txt", Size: 3456 },
+	}
+}
+
+func renderString(t *testing.T, format shell.OutputFormat, records ... interface{}) string {
+	out := new(bytes.Buffer)
+	if err := shell.Render(out, format, records); err != nil {
+		t.Fatal("Could not render", err)
+	}
+	return out.String()
+}
+
+func TestParseFormat(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	format, err := shell.ParseFormat("JSON")
+	assert.Nil(err)
+	assert.Equal(shell.FormatJSON, format)
+	assert.Equal("yaml", shell.FormatYAML.String())
+
+	_, err = shell.ParseFormat("xml")
+	assert.True(errors.Is(err, shell.ErrUnknownFormat))
+	assert.True(errors.Is(shell.Render(new(bytes.Buffer), shell.OutputFormat(9), nil), shell.ErrUnknownFormat))
+}
+
+func TestRender_Table(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	files := renderFiles()
+	assert.Equal(
+		"NAME        SIZE  TAGS     LABELS\n" +
+		"a.txt       12    [x y z]  map[owner:me]\n" +
+		"b long.txt  3456  []       map[]\n",
+		renderString(t, shell.FormatTable, files[0], &files[1]))
+
+	assert.Equal(
+		"HOST  UP\n" +
+		"db    false\n" +
+		"web   true\n",
+		renderString(t, shell.FormatTable,
+			map[string]interface{}{ "host": "db", "up": false },
+			map[string]interface{}{ "host": "web", "up": true }))
+
+	assert.Equal("one\n2\n", renderString(t, shell.FormatTable, "one", 2))
+	assert.Equal("", renderString(t, shell.FormatTable))
+}
+
+func TestRender_JSON(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	assert.Equal("[]\n", renderString(t, shell.FormatJSON))
+	assert.Equal(`[
+  {
+    "name": "b long.txt",
+    "size": 3456,
+    "labels": null
+  }
+]
+`, renderString(t, shell.FormatJSON, renderFiles()[1]))
+}
+
+func TestRender_YAML(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	assert.Equal("[]\n", renderString(t, shell.FormatYAML))
+	assert.Equal(`- name: a.txt
+  size: 12
+  tags:
+    - x
+    - y z
+  labels:
+    owner: me
+- name: b long.txt
+  size: 3456
+  tags: null
+  labels: null
+`, renderString(t, shell.FormatYAML, renderFiles()[0], renderFiles()[1]))
+
+	assert.Equal(`- "true"
+- "12"
+- ""
+- "a: b"
+- "-x"
+- plain text
+- 1.5
+- null
+- nested:
+    - []
+    - {}
+`, renderString(t, shell.FormatYAML, "true", "12", "", "a: b", "-x", "plain text", 1.5, nil,
+		map[string]interface{}{ "nested": []interface{}{ []string{}, map[string]int{} } }))
+}
+
+func TestRender_CSV(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	assert.Equal("name,size,tags,labels\na.txt,12,[x y z],map[owner:me]\nb long.txt,3456,[],map[]\n",
+		renderString(t, shell.FormatCSV, renderFiles()[0], renderFiles()[1]))
+	assert.Equal("\"a,b\"\nc\n", renderString(t, shell.FormatCSV, "a,b", "c"))
+}
+
+func TestInvocation_Emit(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := shell.NewCommandShell("# ", []*shell.Command{
+		{
+			Name:   "files",
+			Flags:  shell.FlagOptionalArgs,
+			Invoke: func(inv *shell.Invocation) error {
+				inv.Emit(renderFiles())
+				if len(inv.Args) > 0 {
+					return errors.New(inv.Args[0])
+				}
+				return nil
+			},
+		},
+	})
+	out := new(bytes.Buffer)
+	cs.Out = out
+
+	assert.Nil(cs.RunCommand([]string{ "files", "--output", "csv" }))
+	assert.Equal("name,size,tags,labels\na.txt,12,[x y z],map[owner:me]\nb long.txt,3456,[],map[]\n", out.String())
+
+	out.Reset()
+	cs.Format = shell.FormatJSON
+	assert.Equal(errors.New("failed"), cs.RunCommand([]string{ "files", "--output=yaml", "failed" }))
+	assert.Equal("- name: a.txt\n", out.String()[:14])
+
+	// Not a format, so the handler gets it
+	out.Reset()
+	assert.Equal(errors.New("--output"), cs.RunCommand([]string{ "files", "--output", "xml" }))
+	assert.Equal("[\n  {\n", out.String()[:6])
+}
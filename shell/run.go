@@ -17,10 +17,13 @@ package shell
 
 import (
 	"github.com/threeguys/golang-ezshell/parser"
+	"github.com/threeguys/golang-ezshell/terminal"
 	"io"
 	"os"
 )
 
+// CommandSupplier provides the commands to run. Read may return the words of
+// a final line which had no line feed together with io.EOF.
 type CommandSupplier interface {
 	Read() ([]string, error)
 }
@@ -47,24 +50,38 @@ func (lcs *ListCommandSupplier) Read() ([]string, error) {
 	}
 }
 
+// RunSupplier runs commands until the supplier is exhausted, which returns
+// nil, a handler returns an ExitError or the error policy stops it. OnExit
+// is called before returning. With Scripting on, commands from a
+// ScriptSupplier may use the control flow described in script.go.
 func (cs *Shell) RunSupplier(rdr CommandSupplier) error {
-	for {
-		if !cs.Quiet {
-			cs.Printf(cs.Prompt)
-		}
-		if parsed, err := rdr.Read(); err != nil {
-			return err
-		} else if err := cs.RunCommand(parsed); err != nil {
-			cs.Println("ERROR:", err)
-		}
+	if cs.OnExit != nil {
+		defer cs.OnExit()
 	}
+	return cs.runSupplier(rdr)
 }
 
+// RunFile runs the commands in the file, a terminal gets the line editor
 func (cs *Shell) RunFile(f *os.File) error {
-	rdr := parser.NewCommandReader(f)
-	return cs.RunSupplier(rdr)
+	if terminal.IsTerminal(f.Fd()) {
+		return cs.RunSupplier(cs.NewInteractiveSupplier(f))
+	}
+	return cs.RunSupplier(parser.NewCommandReader(f))
 }
 
+// Run sources the RcFile and then runs commands from In
 func (cs *Shell) Run() error {
-	return cs.RunFile(os.Stdin)
+	if err := cs.loadRcFile(); err != nil {
+		if _, ok := asExitError(err); ok {
+			if cs.OnExit != nil {
+				cs.OnExit()
+			}
+			return err
+		}
+		cs.printError(err)
+	}
+	if f, ok := cs.In.(*os.File); ok {
+		return cs.RunFile(f)
+	}
+	return cs.RunSupplier(parser.NewCommandReader(cs.In))
 }
@@ -21,8 +21,6 @@ import (
 	"fmt"
 	"github.com/threeguys/golang-ezshell/shell"
 	"github.com/threeguys/golang-toolkit/objects"
-	"io"
-	"os"
 	"strings"
 	"testing"
 )
@@ -100,14 +98,14 @@ func TestShell_RunSupplier(t *testing.T) {
 	supplier := shell.NewListCommandSupplier(cmdList...)
 
 	cs := createMockTestShell()
-	cs.Out = makeTempLog(t)
-	defer func() { assert.Nil(cs.Out.Close()) }()
+	out := new(bytes.Buffer)
+	cs.Out = out
+	cs.Err = out
 
-	assert.Equal(io.EOF, cs.RunSupplier(supplier))
+	assert.Nil(cs.RunSupplier(supplier))
 
 	expected := generateExpectedLog(t, cmdList)
-	logs := getLogData(t, cs.Out)
-	assert.Equal(expected, logs)
+	assert.Equal(expected, out.String())
 }
 
 func TestShell_RunFile(t *testing.T) {
@@ -124,14 +122,14 @@ func TestShell_RunFile(t *testing.T) {
 	resetTempFile(t, in)
 
 	cs := createMockTestShell()
-	cs.Out = makeTempLog(t)
-	defer func() { assert.Nil(cs.Out.Close()) }()
+	out := new(bytes.Buffer)
+	cs.Out = out
+	cs.Err = out
 
-	assert.Equal(io.EOF, cs.RunFile(in))
+	assert.Nil(cs.RunFile(in))
 
 	expected := generateExpectedLog(t, cmdList)
-	logs := getLogData(t, cs.Out)
-	assert.Equal(expected, logs)
+	assert.Equal(expected, out.String())
 }
 
 func TestShell_Run(t *testing.T) {
@@ -148,16 +146,62 @@ func TestShell_Run(t *testing.T) {
 	resetTempFile(t, in)
 
 	cs := createMockTestShell()
-	cs.Out = makeTempLog(t)
-	defer func() { assert.Nil(cs.Out.Close()) }()
-
-	oldStdin := os.Stdin
-	os.Stdin = in
-	err := cs.Run()
-	os.Stdin = oldStdin
-	assert.Equal(io.EOF, err)
+	out := new(bytes.Buffer)
+	cs.Out = out
+	cs.In = in
+	assert.Nil(cs.Run())
 
 	expected := generateExpectedLog(t, cmdList)
-	logs := getLogData(t, cs.Out)
-	assert.Equal(expected, logs)
+	assert.Equal(expected, out.String())
+}
+
+func TestShell_Run_Streams(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createMockTestShell()
+	cs.Echo = false
+	out := new(bytes.Buffer)
+	errOut := new(bytes.Buffer)
+	cs.Out = out
+	cs.Err = errOut
+	cs.In = strings.NewReader("noop\nerr\nnoop")
+
+	assert.Nil(cs.Run())
+	assert.Equal("# SUCCESS\n# # SUCCESS\n", out.String())
+	assert.Equal("ERROR: i made an error\n", errOut.String())
+}
+
+func TestShell_RunFile_NoFinalLineFeed(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	in := makeTempLog(t)
+	defer func() { assert.Nil(in.Close()) }()
+	_, err := fmt.Fprint(in, "noop\n\n   \nnoop")
+	assert.Nil(err)
+	resetTempFile(t, in)
+
+	cs := createMockTestShell()
+	cs.Echo = false
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+
+	assert.Nil(cs.RunFile(in))
+	assert.Equal("# SUCCESS\n# # # SUCCESS\n", getLogData(t, out))
+}
+
+func TestShell_OnExit(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := createMockTestShell()
+	cs.Quiet = true
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+
+	exits := 0
+	cs.OnExit = func() { exits++ }
+	assert.Nil(cs.RunSupplier(shell.NewListCommandSupplier([]string{ "noop" })))
+	assert.Equal(1, exits)
+
+	cs.ErrorPolicy = shell.StopOnError
+	assert.NotNil(cs.RunSupplier(shell.NewListCommandSupplier([]string{ "err" })))
+	assert.Equal(2, exits)
 }
@@ -0,0 +1,668 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"github.com/threeguys/golang-ezshell/parser"
+	"github.com/threeguys/golang-ezshell/terminal"
+	"io"
+)
+
+const (
+	MaxCallDepth = 64
+)
+
+var (
+	ErrSyntax = errors.New("syntax error")
+	ErrCallDepth = errors.New("functions nested too deeply")
+
+	errIncomplete = errors.New("incomplete statement")
+	errNegated = errors.New("negated condition")
+)
+
+// Keywords can't be used as the first word of a command
+var keywords = map[string]bool{
+	"if": true, "then": true, "elif": true, "else": true, "fi": true,
+	"for": true, "while": true, "do": true, "done": true,
+	"function": true, "{": true, "}": true,
+}
+
+// ScriptSupplier is implemented by suppliers which can read a line with its
+// quoting and operators kept, which variables, ';' and '|' need. Only these
+// suppliers run scripts, the words from Read are always run as they are.
+type ScriptSupplier interface {
+	CommandSupplier
+	ReadScript() ([]parser.Token, error)
+}
+
+// statement is the tokens between two ';' or line feeds
+type statement struct {
+	tokens []parser.Token
+	line int
+}
+
+func (st statement) keyword() string {
+	if len(st.tokens) > 0 && st.tokens[0].Kind == parser.TokenWord && st.tokens[0].Quote == 0 &&
+		keywords[st.tokens[0].Text] {
+		return st.tokens[0].Text
+	}
+	return ""
+}
+
+func (st statement) syntaxError(near string) error {
+	return &ScriptError{
+		Line:    st.line,
+		Command: parser.Words(st.tokens),
+		Err:     fmt.Errorf("%w near '%s'", ErrSyntax, near),
+	}
+}
+
+// splitStatements splits a line on ';' and puts keywords which may be
+// followed by a command, like "then" or "while", in a statement of their own
+func splitStatements(tokens []parser.Token, line int) []statement {
+	stmts := make([]statement, 0)
+	add := func(tokens []parser.Token) {
+		if len(tokens) == 0 {
+			return
+		}
+		st := statement{ tokens: tokens, line: line }
+		switch st.keyword() {
+		case "if", "elif", "while", "then", "else", "do", "{":
+			if len(tokens) > 1 {
+				stmts = append(stmts, statement{ tokens: tokens[:1], line: line })
+				st.tokens = tokens[1:]
+			}
+		case "function":
+			if len(tokens) > 3 && tokens[2].Text == "{" {
+				stmts = append(stmts, statement{ tokens: tokens[:3], line: line })
+				st.tokens = tokens[3:]
+			}
+		}
+		stmts = append(stmts, st)
+	}
+
+	start := 0
+	for i, t := range tokens {
+		if t.IsOperator(";") {
+			add(tokens[start:i])
+			start = i + 1
+		}
+	}
+	add(tokens[start:])
+	return stmts
+}
+
+type scriptNode interface{}
+
+// commandNode is a command or a pipeline of them, stages holds the tokens
+// of each command in the pipeline
+type commandNode struct {
+	tokens []parser.Token
+	stages [][]parser.Token
+	line int
+	negate bool
+}
+
+type ifNode struct {
+	cond []scriptNode
+	body []scriptNode
+	orElse []scriptNode
+}
+
+type whileNode struct {
+	cond []scriptNode
+	body []scriptNode
+}
+
+type forNode struct {
+	name string
+	items []parser.Token
+	body []scriptNode
+}
+
+type functionNode struct {
+	name string
+	body []scriptNode
+}
+
+// scriptParser collects statements until they make up complete commands,
+// so a block can be spread over as many lines as needed
+type scriptParser struct {
+	stmts []statement
+	pos int
+}
+
+// feed adds a line and returns the commands it completes, if any
+func (sp *scriptParser) feed(tokens []parser.Token, line int) ([]scriptNode, error) {
+	sp.stmts = append(sp.stmts, splitStatements(tokens, line)...)
+	sp.pos = 0
+	nodes, err := sp.parseList()
+	if err == errIncomplete {
+		return nil, nil
+	}
+	sp.stmts = nil
+	return nodes, err
+}
+
+// pending reports whether a block has been started but not finished
+func (sp *scriptParser) pending() bool {
+	return len(sp.stmts) > 0
+}
+
+// parseList parses commands until one of the terminating keywords, which
+// is left for the caller. Running out of statements before finding one
+// means more lines are needed.
+func (sp *scriptParser) parseList(terminators ... string) ([]scriptNode, error) {
+	nodes := make([]scriptNode, 0)
+	for {
+		if sp.pos >= len(sp.stmts) {
+			if len(terminators) > 0 {
+				return nil, errIncomplete
+			}
+			return nodes, nil
+		}
+
+		st := sp.stmts[sp.pos]
+		kw := st.keyword()
+		for _, t := range terminators {
+			if kw == t {
+				return nodes, nil
+			}
+		}
+
+		var node scriptNode
+		var err error
+		switch kw {
+		case "if":
+			node, err = sp.parseIf()
+		case "while":
+			node, err = sp.parseWhile()
+		case "for":
+			node, err = sp.parseFor()
+		case "function":
+			node, err = sp.parseFunction()
+		case "":
+			node, err = newCommandNode(st)
+			sp.pos++
+		default:
+			return nil, st.syntaxError(kw)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+}
+
+func newCommandNode(st statement) (*commandNode, error) {
+	node := &commandNode{ tokens: st.tokens, line: st.line }
+	if first := st.tokens[0]; first.Quote == 0 && first.Text == "!" {
+		node.tokens = st.tokens[1:]
+		node.negate = true
+	}
+
+	start := 0
+	for i, t := range node.tokens {
+		if t.IsOperator("|") {
+			if i == start {
+				return nil, st.syntaxError("|")
+			}
+			node.stages = append(node.stages, node.tokens[start:i])
+			start = i + 1
+		}
+	}
+	if start > 0 && start == len(node.tokens) {
+		return nil, st.syntaxError("|")
+	}
+	node.stages = append(node.stages, node.tokens[start:])
+	return node, nil
+}
+
+// expect consumes a statement which must be just the keyword
+func (sp *scriptParser) expect(kw string) error {
+	if sp.pos >= len(sp.stmts) {
+		return errIncomplete
+	}
+	st := sp.stmts[sp.pos]
+	if st.keyword() != kw {
+		return st.syntaxError(st.tokens[0].Text)
+	} else if len(st.tokens) > 1 {
+		return st.syntaxError(st.tokens[1].Text)
+	}
+	sp.pos++
+	return nil
+}
+
+// parseBody parses the commands up to the keyword and consumes it
+func (sp *scriptParser) parseBody(end string) ([]scriptNode, error) {
+	if body, err := sp.parseList(end); err != nil {
+		return nil, err
+	} else if len(body) == 0 {
+		return nil, sp.stmts[sp.pos].syntaxError(end)
+	} else {
+		return body, sp.expect(end)
+	}
+}
+
+// parseIf handles both if and elif, an elif becomes an if in the else
+// branch which consumes the fi
+func (sp *scriptParser) parseIf() (scriptNode, error) {
+	sp.pos++
+	node := &ifNode{}
+	var err error
+	if node.cond, err = sp.parseBody("then"); err != nil {
+		return nil, err
+	} else if node.body, err = sp.parseList("elif", "else", "fi"); err != nil {
+		return nil, err
+	}
+
+	switch sp.stmts[sp.pos].keyword() {
+	case "elif":
+		elif, err := sp.parseIf()
+		node.orElse = []scriptNode{ elif }
+		return node, err
+	case "else":
+		sp.pos++
+		node.orElse, err = sp.parseList("fi")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return node, sp.expect("fi")
+}
+
+func (sp *scriptParser) parseWhile() (scriptNode, error) {
+	sp.pos++
+	node := &whileNode{}
+	var err error
+	if node.cond, err = sp.parseBody("do"); err != nil {
+		return nil, err
+	} else if node.body, err = sp.parseBody("done"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// parseFor handles for <name> in <words...>
+func (sp *scriptParser) parseFor() (scriptNode, error) {
+	st := sp.stmts[sp.pos]
+	if len(st.tokens) < 3 || !IsVarName(st.tokens[1].Text) || st.tokens[2].Text != "in" {
+		return nil, st.syntaxError("for")
+	}
+	sp.pos++
+
+	node := &forNode{ name: st.tokens[1].Text, items: st.tokens[3:] }
+	var err error
+	if err = sp.expect("do"); err != nil {
+		return nil, err
+	} else if node.body, err = sp.parseBody("done"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// parseFunction handles function <name> { ... } with the brace on the same
+// line or the next one
+func (sp *scriptParser) parseFunction() (scriptNode, error) {
+	st := sp.stmts[sp.pos]
+	if len(st.tokens) < 2 || len(st.tokens) > 3 || keywords[st.tokens[1].Text] {
+		return nil, st.syntaxError("function")
+	}
+	sp.pos++
+
+	if len(st.tokens) == 3 {
+		if st.tokens[2].Text != "{" {
+			return nil, st.syntaxError(st.tokens[2].Text)
+		}
+	} else if err := sp.expect("{"); err != nil {
+		return nil, err
+	}
+
+	node := &functionNode{ name: st.tokens[1].Text }
+	var err error
+	if node.body, err = sp.parseBody("}"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// scriptRun holds the state of a script run, errors inside loops and
+// functions count towards the error policy like any other
+type scriptRun struct {
+	errCount int
+	depth int
+}
+
+// fail reports a failed command and returns stop if the error policy says
+// the script should end
+func (cs *Shell) fail(run *scriptRun, err error, stop error) error {
+	cs.printError(err)
+	run.errCount++
+	if cs.shouldStop(run.errCount) {
+		return stop
+	}
+	return nil
+}
+
+// execNodes runs the commands in order. The status is the error of the last
+// command, or nil if it succeeded, and stop is set when the script has to
+// end early because of exit or the error policy.
+func (cs *Shell) execNodes(run *scriptRun, nodes []scriptNode, condition bool) (status error, stop error) {
+	for _, node := range nodes {
+		if status, stop = cs.execNode(run, node, condition); stop != nil {
+			return status, stop
+		}
+	}
+	return status, nil
+}
+
+func (cs *Shell) execNode(run *scriptRun, node scriptNode, condition bool) (error, error) {
+	switch n := node.(type) {
+	case *commandNode:
+		status, stop := cs.execCommand(run, n, condition)
+		cs.setLastStatus(status)
+		return status, stop
+
+	case *ifNode:
+		if status, stop := cs.execNodes(run, n.cond, true); stop != nil {
+			return status, stop
+		} else if status == nil {
+			return cs.execNodes(run, n.body, condition)
+		}
+		return cs.execNodes(run, n.orElse, condition)
+
+	case *whileNode:
+		var status error
+		for {
+			if cond, stop := cs.execNodes(run, n.cond, true); stop != nil {
+				return cond, stop
+			} else if cond != nil {
+				return status, nil
+			}
+
+			var stop error
+			if status, stop = cs.execNodes(run, n.body, condition); stop != nil {
+				return status, stop
+			}
+		}
+
+	case *forNode:
+		var status error
+		for _, item := range cs.expandTokens(n.items) {
+			cs.SetVar(n.name, item)
+			var stop error
+			if status, stop = cs.execNodes(run, n.body, condition); stop != nil {
+				return status, stop
+			}
+		}
+		return status, nil
+
+	case *functionNode:
+		cs.lock.Lock()
+		if cs.functions == nil {
+			cs.functions = make(map[string]*functionNode)
+		}
+		cs.functions[n.name] = n
+		cs.lock.Unlock()
+		return nil, nil
+
+	default:
+		return fmt.Errorf("unknown script node %T", node), nil
+	}
+}
+
+// function returns the user function with the name, if there is one
+func (cs *Shell) function(name string) *functionNode {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	return cs.functions[name]
+}
+
+// execCommand runs a single command. Failures are reported as they happen,
+// except in conditions where failing just means false.
+func (cs *Shell) execCommand(run *scriptRun, n *commandNode, condition bool) (error, error) {
+	condition = condition || n.negate
+	negate := func(err error) error {
+		if !n.negate {
+			return err
+		} else if err == nil {
+			return errNegated
+		}
+		return nil
+	}
+
+	if len(n.stages) > 1 {
+		return cs.execPipeline(run, n, condition, negate)
+	}
+
+	if name, value, ok := assignment(n.tokens); ok {
+		expanded, _ := cs.expand(value)
+		cs.SetVar(name, expanded)
+		return negate(nil), nil
+	}
+
+	words := cs.expandTokens(n.tokens)
+	if len(words) == 0 {
+		return negate(nil), nil
+	}
+
+	var err error
+	if fn := cs.function(words[0]); fn == nil {
+		err = cs.runRecovered(words)
+	} else if run.depth >= MaxCallDepth {
+		err = ErrCallDepth
+	} else {
+		// Errors inside the function have already been reported
+		status, stop := cs.callFunction(run, fn, words[1:], condition)
+		return negate(status), stop
+	}
+
+	if err == nil {
+		return negate(nil), nil
+	} else if _, ok := asExitError(err); ok {
+		return err, err
+	} else if condition {
+		return negate(err), nil
+	}
+	return err, cs.fail(run, err, &ScriptError{ Line: n.line, Command: words, Err: err })
+}
+
+// functionFailed is the status of a function in a pipeline, the errors
+// inside it have already been reported
+type functionFailed struct {
+	err error
+}
+
+func (ff *functionFailed) Error() string {
+	return ff.err.Error()
+}
+
+// execPipeline runs a command with more than one stage. A function in the
+// pipeline runs with the stage's streams, so what its commands print is
+// passed on like the output of any other command.
+func (cs *Shell) execPipeline(run *scriptRun, n *commandNode, condition bool, negate func(error) error) (error, error) {
+	stages := make([]stage, 0, len(n.stages))
+	command := make([]string, 0)
+	var err error
+	var stop error
+	for i, tokens := range n.stages {
+		words := cs.expandTokens(tokens)
+		if i > 0 {
+			command = append(command, "|")
+		}
+		command = append(command, words...)
+
+		if len(words) == 0 {
+			err = fmt.Errorf("%w: empty command in pipeline", ErrSyntax)
+		} else if fn := cs.function(words[0]); fn == nil {
+			stages = append(stages, func(inv *Invocation) error { return cs.invoke(words, inv) })
+		} else {
+			stages = append(stages, func(inv *Invocation) error {
+				if run.depth >= MaxCallDepth {
+					return ErrCallDepth
+				}
+				defer cs.redirect(inv)()
+				var status error
+				if status, stop = cs.callFunction(run, fn, words[1:], condition); stop != nil {
+					return stop
+				} else if status != nil {
+					return &functionFailed{ status }
+				}
+				return nil
+			})
+		}
+	}
+	if err == nil {
+		err = recovered(func() error { return cs.runStages(stages) })
+	}
+
+	var ff *functionFailed
+	if stop != nil {
+		return err, stop
+	} else if errors.As(err, &ff) {
+		return negate(ff.err), nil
+	} else if err == nil {
+		return negate(nil), nil
+	} else if _, ok := asExitError(err); ok {
+		return err, err
+	} else if condition {
+		return negate(err), nil
+	}
+	return err, cs.fail(run, err, &ScriptError{ Line: n.line, Command: command, Err: err })
+}
+
+// callFunction runs the function body with $1, $2... set to the arguments
+func (cs *Shell) callFunction(run *scriptRun, fn *functionNode, args []string, condition bool) (error, error) {
+	saved := cs.positional
+	cs.positional = args
+	run.depth++
+	defer func() {
+		run.depth--
+		cs.positional = saved
+	}()
+	return cs.execNodes(run, fn.body, condition)
+}
+
+// runSupplier reads and runs commands from the supplier. With Scripting on
+// statements which start a block are held until the block is complete, and
+// ctrl-c throws away a block which is being typed.
+func (cs *Shell) runSupplier(rdr CommandSupplier) error {
+	run := &scriptRun{}
+	sp := &scriptParser{}
+	for line := 1; ; line++ {
+		script, ok := rdr.(ScriptSupplier)
+		scripting := ok && (cs.scripting() || sp.pending())
+		pipeline, pipes := rdr.(PipelineSupplier)
+		pipes = pipes && !cs.NoPipes
+
+		cs.printPrompt(rdr, sp.pending())
+		var tokens []parser.Token
+		var stages [][]string
+		var readErr error
+		if scripting {
+			tokens, readErr = script.ReadScript()
+		} else if pipes {
+			tokens, readErr = pipeline.ReadPipeline()
+			stages = splitStages(tokens)
+		} else {
+			var words []string
+			words, readErr = rdr.Read()
+			stages = [][]string{ words }
+		}
+		cs.donePrompting()
+
+		if readErr == terminal.ErrInterrupted {
+			sp.stmts = nil
+			continue
+		} else if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+
+		if !scripting {
+			if stop := cs.execWords(run, stages, line); stop != nil {
+				return stop
+			}
+		} else {
+			nodes, err := sp.feed(tokens, line)
+			if err != nil {
+				if stop := cs.fail(run, err, err); stop != nil {
+					return stop
+				}
+			}
+
+			for _, node := range nodes {
+				if _, stop := cs.execNode(run, node, false); stop != nil {
+					return stop
+				}
+			}
+		}
+
+		if readErr == io.EOF {
+			if sp.pending() {
+				st := sp.stmts[len(sp.stmts)-1]
+				err := &ScriptError{ Line: st.line, Command: parser.Words(st.tokens), Err: fmt.Errorf("%w: unexpected end of input", ErrSyntax) }
+				return cs.fail(run, err, err)
+			}
+			return nil
+		}
+	}
+}
+
+// splitStages splits the tokens of a pipeline into the words of each command
+func splitStages(tokens []parser.Token) [][]string {
+	stages := make([][]string, 0)
+	start := 0
+	for i, t := range tokens {
+		if t.IsOperator("|") {
+			stages = append(stages, parser.Words(tokens[start:i]))
+			start = i + 1
+		}
+	}
+	return append(stages, parser.Words(tokens[start:]))
+}
+
+// execWords runs a line of words as they are, without any script syntax.
+// There's more than one stage when the line was a pipeline.
+func (cs *Shell) execWords(run *scriptRun, stages [][]string, line int) error {
+	command := make([]string, 0)
+	var err error
+	for i, words := range stages {
+		if i > 0 {
+			command = append(command, "|")
+		}
+		command = append(command, words...)
+		if len(words) == 0 && len(stages) > 1 {
+			err = fmt.Errorf("%w: empty command in pipeline", ErrSyntax)
+		}
+	}
+	if len(command) == 0 {
+		return nil
+	}
+
+	if err == nil {
+		err = cs.runRecovered(stages...)
+	}
+	cs.setLastStatus(err)
+	if err == nil {
+		return nil
+	} else if _, ok := asExitError(err); ok {
+		return err
+	}
+	return cs.fail(run, err, &ScriptError{ Line: line, Command: command, Err: err })
+}
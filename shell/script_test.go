@@ -0,0 +1,239 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"errors"
+	"github.com/threeguys/golang-ezshell/parser"
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"os"
+	"strings"
+	"testing"
+)
+
+func createScriptShell(t *testing.T) (*shell.Shell, *os.File) {
+	cs := createMockTestShell()
+	cs.Echo = false
+	cs.Quiet = true
+	cs.Scripting = true
+	out := makeTempLog(t)
+	cs.Out = out
+	cs.Err = out
+
+	echo := &shell.Command{
+		Name:        "echo",
+		Description: "print the arguments",
+		Handler: func(args []string) error {
+			cs.Println(strings.Join(args, " "))
+			return nil
+		},
+	}
+	if err := cs.AddCommand("global", echo); err != nil {
+		t.Fatal("Could not add echo", err)
+	}
+	return cs, out
+}
+
+func runScriptText(t *testing.T, script string) (string, error) {
+	cs, out := createScriptShell(t)
+	defer func() { _ = out.Close() }()
+	f, err := os.Open(writeTempScript(t, t.TempDir(), "test.ez", script))
+	if err != nil {
+		t.Fatal("Could not open script", err)
+	}
+	defer func() { _ = f.Close() }()
+	err = cs.RunFile(f)
+	return getLogData(t, out), err
+}
+
+func TestScript_If(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+
+	out, err := runScriptText(t, "if noop; then echo yes; else echo no; fi\nif err; then echo yes; else echo no; fi\n")
+	assert.Nil(err)
+	assert.Equal("SUCCESS\nyes\nno\n", out)
+
+	out, err = runScriptText(t, `
+if err
+then
+	echo one
+elif ! noop
+then
+	echo two
+elif ! err; then
+	echo three
+else
+	echo four
+fi
+if err; then echo skipped; fi
+echo done`)
+	assert.Nil(err)
+	assert.Equal("SUCCESS\nthree\ndone\n", out)
+}
+
+func TestScript_Loops(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+
+	out, err := runScriptText(t, "for x in a 'b c' $missing; do echo item $x; done\n")
+	assert.Nil(err)
+	assert.Equal("item a\nitem b c\n", out)
+
+	out, err = runScriptText(t, "set \"list=1 2 3\"\nfor n in $list\ndo\n\techo \"n=$n\"\ndone\n")
+	assert.Nil(err)
+	assert.Equal("n=1\nn=2\nn=3\n", out)
+
+}
+
+func TestScript_While(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs, out := createScriptShell(t)
+	defer func() { assert.Nil(out.Close()) }()
+
+	remaining := 3
+	assert.Nil(cs.AddCommand("global", &shell.Command{
+		Name:    "more",
+		Handler: func(_ []string) error {
+			if remaining == 0 {
+				return errors.New("no more")
+			}
+			remaining--
+			return nil
+		},
+	}))
+
+	assert.Nil(cs.RunSupplier(parser.NewCommandReader(strings.NewReader("while more\ndo\necho $remaining\ndone\n"))))
+	assert.Equal(0, remaining)
+	assert.Equal("\n\n\n", getLogData(t, out))
+}
+
+func TestScript_Functions(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+
+	out, err := runScriptText(t, `
+function greet {
+	echo hello $1 from $# args: "$@"
+}
+function check { err; }
+greet world
+greet a b
+if check; then echo ok; else echo not ok; fi
+function noop { echo overridden; }
+noop
+`)
+	assert.Nil(err)
+	assert.Equal("hello world from 1 args: world\nhello a from 2 args: a b\nnot ok\noverridden\n", out)
+
+	out, err = runScriptText(t, "function loop { loop; }\nloop\necho after\n")
+	assert.Nil(err)
+	assert.Equal("ERROR: functions nested too deeply\nafter\n", out)
+}
+
+func TestScript_FunctionPipeline(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+
+	out, err := runScriptText(t, `
+function greet { echo hello $1; echo bye $1; }
+function fails { err; }
+greet bob | grep bye
+greet bob | greet sue | wc -l
+if greet x | grep nothing; then echo matched; else echo no match; fi
+fails | wc -l
+echo after
+`)
+	assert.Nil(err)
+	assert.Equal("bye bob\n2\nno match\nERROR: i made an error\nafter\n", out)
+
+	out, err = runScriptText(t, "set -e\nfunction fails { err; }\nfails | wc -l\necho never\n")
+	assert.NotNil(err)
+	assert.Equal("ERROR: i made an error\n", out)
+}
+
+func TestScript_Errors(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+
+	// Failures inside blocks follow the error policy
+	out, err := runScriptText(t, "set -e\nfor x in a b; do\n\terr\n\techo $x\ndone\necho never\n")
+	var se *shell.ScriptError
+	assert.True(errors.As(err, &se))
+	assert.Equal(3, se.Line)
+	assert.Equal([]string{ "err" }, se.Command)
+	assert.Equal("ERROR: i made an error\n", out)
+
+	// Conditions can fail without stopping the script
+	out, err = runScriptText(t, "set -e\nif err; then echo yes; fi\necho after\n")
+	assert.Nil(err)
+	assert.Equal("after\n", out)
+
+	out, err = runScriptText(t, "echo before\nfi\necho after\n")
+	assert.Nil(err)
+	assert.Equal("before\nERROR: line 2: fi: syntax error near 'fi'\nafter\n", out)
+
+	out, err = runScriptText(t, "set -e\necho before\nif noop; then\necho never\n")
+	assert.True(errors.As(err, &se))
+	assert.True(errors.Is(err, shell.ErrSyntax))
+	assert.Equal(4, se.Line)
+	assert.Equal("before\nERROR: line 4: echo never: syntax error: unexpected end of input\n", out)
+
+	_, err = runScriptText(t, "set -e\nfor 1 in a; do echo x; done\n")
+	assert.True(errors.Is(err, shell.ErrSyntax))
+
+	out, err = runScriptText(t, "while noop; do exit 4; done\necho never\n")
+	assert.Equal(shell.Exit(4), err)
+	assert.Equal("SUCCESS\n", out)
+}
+
+func TestScript_Off(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs, out := createScriptShell(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.SetVar("name", "world")
+
+	// Without scripting each line is one command, run as it was typed
+	cs.Scripting = false
+	cs.NoPipes = true
+	assert.Nil(cs.RunSupplier(parser.NewCommandReader(strings.NewReader("echo a;b $name 'x y' a|b\nx=1\nif noop\n"))))
+	assert.Equal("a;b $name x y a|b\nERROR: no matching command found\nERROR: no matching command found\n", getLogData(t, out))
+	assert.Equal(map[string]string{ "name": "world" }, cs.Vars())
+
+	// Unless NoPipes is set '|' still makes a pipeline
+	assert.Nil(out.Truncate(0))
+	_, _ = out.Seek(0, 0)
+	cs.NoPipes = false
+	assert.Nil(cs.RunSupplier(parser.NewCommandReader(strings.NewReader("echo a;b $name | grep a;b\necho 'a|b' |\n"))))
+	assert.Equal("a;b $name\nERROR: syntax error: empty command in pipeline\n", getLogData(t, out))
+
+	// Words which have already been split are never treated as script
+	assert.Nil(out.Truncate(0))
+	_, _ = out.Seek(0, 0)
+	cs.Scripting = true
+	assert.Nil(cs.RunSupplier(shell.NewListCommandSupplier(
+		[]string{ "echo", "$name", ";", "|", "!" },
+		[]string{ "if", "noop" },
+	)))
+	assert.Equal("$name ; | !\nERROR: no matching command found\n", getLogData(t, out))
+}
+
+func TestScript_ContinuationPrompt(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs, out := createScriptShell(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Quiet = false
+	cs.ContinuationPrompt = "... "
+
+	assert.Nil(cs.RunSupplier(parser.NewCommandReader(strings.NewReader("for x in a b\ndo echo $x\ndone\n"))))
+	assert.Equal("# ... ... a\nb\n# ", getLogData(t, out))
+}
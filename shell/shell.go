@@ -16,10 +16,18 @@
 package shell
 
 import (
+	"errors"
 	"fmt"
+	"github.com/threeguys/golang-ezshell/terminal"
+	"io"
 	"log"
 	"os"
 	"strings"
+	"sync"
+)
+
+var (
+	ErrModeExists = errors.New("mode already exists")
 )
 
 type Printer interface {
@@ -27,20 +35,59 @@ type Printer interface {
 	Printf(fmtStr string, vars ... interface{})
 }
 
+// PartialLineSupplier is implemented by suppliers which can report the
+// line the user has typed so far, so asynchronous output can redraw it
+type PartialLineSupplier interface {
+	CommandSupplier
+	PartialLine() string
+}
+
+// Shell is safe for concurrent use as long as the modes are changed through
+// SwitchMode, AddMode, AddCommand and RemoveCommand and read with CurrentMode
 type Shell struct {
 	modes []*CommandMode
 	modeIndex map[string]*CommandMode
+	builtins *CommandMode
 	Mode *CommandMode
 	Global *CommandMode
 	Prompt string
-	Out *os.File
+	ContinuationPrompt string
+	Out io.Writer
+	Err io.Writer
+	In io.Reader
+	Format OutputFormat
+	Theme Theme // change it with the theme command, or before running the shell
+	Color ColorMode
 	Echo bool
 	Quiet bool
+	Debug bool
+	ErrorPolicy ErrorPolicy
+	MaxErrors int
+	Scripting bool // variables, ';', functions and the script.go control flow, set -o scripting
+	OnExit func()
+	RcFile string
+	NoPager bool // never page long output, see Command.NoPager
+	NoAutosuggest bool
+	NoPipes bool // without scripting '|' still joins commands into a pipeline unless this is set
+	Keymap terminal.Keymap // the line editor's keys, set -o vi or set -o emacs
+
+	middleware []Middleware
+	lock sync.RWMutex
+	outLock sync.Mutex
+	prompting bool
+	sourceDepth int
+	supplier CommandSupplier
+	activePrompt string
+	vars map[string]string
+	functions map[string]*functionNode
+	positional []string
+	lastStatus int
+	current *Invocation
 }
 
 func NewCommandShell(prompt string, global []*Command, cmd ... *CommandMode) *Shell {
 	var cs *Shell
-	globalMode := newGlobalMode(HandlerWrap(func() { cs.PrintHelp() }), global)
+	globalMode := newGlobalMode(func(inv *Invocation) error { return cs.helpInvoke(inv) }, global)
 
 	for _, c := range cmd {
 		c.Delegate = globalMode
@@ -63,27 +110,167 @@ func NewCommandShell(prompt string, global []*Command, cmd ... *CommandMode) *Sh
 		Mode:      defaultMode,
 		Global:    globalMode,
 		Prompt:    prompt,
+		ContinuationPrompt: "> ",
 		Out:       os.Stdout,
+		Err:       os.Stderr,
+		In:        os.Stdin,
+		Theme:     DefaultTheme,
 		Echo:      false,
 		Quiet:     false,
+		Debug:     false,
 	}
+	cs.builtins = newBuiltinMode(cs, globalMode.Delegate)
+	globalMode.Delegate = cs.builtins
 
 	return cs
 }
 
-func (cs *Shell) Println(out ... interface{}) {
-	if _, err := fmt.Fprintln(cs.Out, out...); err != nil {
+// write sends the whole string to the output in a single call so output
+// from concurrent goroutines is never interleaved mid-line
+// write prints to the output of the command which is running, if there is
+// one, so output from Printf can be piped and paged like the invocation's
+func (cs *Shell) write(out string) {
+	cs.outLock.Lock()
+	if inv := cs.current; inv != nil {
+		cs.outLock.Unlock()
+		if _, err := io.WriteString(inv.Stdout, out); err != nil {
+			log.Println("Unable to write to output", err)
+		}
+		return
+	}
+	defer cs.outLock.Unlock()
+	cs.writeLocked(out)
+}
+
+// redirect makes inv the running command until the returned func is called
+func (cs *Shell) redirect(inv *Invocation) func() {
+	cs.outLock.Lock()
+	defer cs.outLock.Unlock()
+	saved := cs.current
+	cs.current = inv
+	return func() {
+		cs.outLock.Lock()
+		defer cs.outLock.Unlock()
+		cs.current = saved
+	}
+}
+
+func (cs *Shell) writeLocked(out string) {
+	if _, err := io.WriteString(cs.Out, out); err != nil {
 		log.Println("Unable to write to output file", err)
 	}
 }
 
+// writeErr sends the string to the error stream, it shares the output lock
+// so errors and output stay in order when both go to the same place
+func (cs *Shell) writeErr(out string) {
+	cs.outLock.Lock()
+	defer cs.outLock.Unlock()
+	if _, err := io.WriteString(cs.Err, out); err != nil {
+		log.Println("Unable to write to error stream", err)
+	}
+}
+
+// Println and Printf print to the output of the command which is running, or
+// Out between commands. Goroutines printing in the background should use
+// AsyncPrintf instead.
+func (cs *Shell) Println(out ... interface{}) {
+	cs.write(fmt.Sprintln(out...))
+}
+
 func (cs *Shell) Printf(fmtStr string, vars ... interface{}) {
-	if _, err := fmt.Fprintf(cs.Out, fmtStr, vars...); err != nil {
-		log.Println("Unable to write to output file", err)
+	cs.write(fmt.Sprintf(fmtStr, vars...))
+}
+
+// AsyncPrintf is meant for goroutines printing while the shell is waiting
+// for input. The message is printed above the prompt, which is then redrawn
+// along with anything the user had already typed.
+func (cs *Shell) AsyncPrintf(fmtStr string, vars ... interface{}) {
+	msg := fmt.Sprintf(fmtStr, vars...)
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+
+	cs.outLock.Lock()
+	defer cs.outLock.Unlock()
+
+	if !cs.prompting {
+		cs.writeLocked(msg)
+		return
+	} else if ap, ok := cs.supplier.(abovePrinter); ok {
+		ap.PrintAbove(msg)
+		return
+	}
+
+	partial := ""
+	if pls, ok := cs.supplier.(PartialLineSupplier); ok {
+		partial = pls.PartialLine()
+	}
+	cs.writeLocked("\r\x1b[K" + msg + cs.activePrompt + partial)
+}
+
+// abovePrinter is implemented by suppliers which can print above the line
+// being typed and redraw it themselves
+type abovePrinter interface {
+	PrintAbove(msg string)
+}
+
+// printPrompt shows the prompt and marks the shell as waiting on the supplier,
+// the continuation prompt is used while a block is being typed. Variables in
+// the prompt are expanded, so it can show $? for example.
+func (cs *Shell) printPrompt(rdr CommandSupplier, continuation bool) {
+	prompt := cs.Prompt
+	if continuation {
+		prompt = cs.ContinuationPrompt
+	}
+	prompt, _ = cs.expand(prompt)
+	prompt = cs.themeFor(cs.Out).Prompt.Apply(prompt)
+
+	cs.outLock.Lock()
+	defer cs.outLock.Unlock()
+	cs.activePrompt = prompt
+	if ps, ok := rdr.(PromptSupplier); ok {
+		ps.SetPrompt(cs.activePrompt)
+	} else if !cs.Quiet {
+		cs.writeLocked(cs.activePrompt)
 	}
+	cs.prompting = !cs.Quiet
+	cs.supplier = rdr
+}
+
+func (cs *Shell) donePrompting() {
+	cs.outLock.Lock()
+	defer cs.outLock.Unlock()
+	cs.prompting = false
+	cs.supplier = nil
+}
+
+// scripting reports whether script syntax is on, set may be changing it
+// while the prompt and line editor read it
+func (cs *Shell) scripting() bool {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	return cs.Scripting
+}
+
+// CurrentMode returns the active mode, use it instead of reading Mode
+// when other goroutines may be switching modes
+func (cs *Shell) CurrentMode() *CommandMode {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	return cs.Mode
+}
+
+// Modes returns the modes given to NewCommandShell or added with AddMode
+func (cs *Shell) Modes() []*CommandMode {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	return append([]*CommandMode{}, cs.modes...)
 }
 
 func (cs *Shell) SwitchMode(name string) error {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
 	if mode, ok := cs.modeIndex[name]; !ok {
 		return ErrNoMatch
 	} else {
@@ -92,13 +279,109 @@ func (cs *Shell) SwitchMode(name string) error {
 	}
 }
 
+// AddMode registers a new mode after the shell has been created
+func (cs *Shell) AddMode(mode *CommandMode) error {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if _, ok := cs.modeIndex[mode.Name]; ok {
+		return ErrModeExists
+	}
+	mode.Delegate = cs.Global
+	cs.modes = append(cs.modes, mode)
+	cs.modeIndex[mode.Name] = mode
+	return nil
+}
+
+// AddCommand adds a command to the named mode
+func (cs *Shell) AddCommand(modeName string, cmd *Command) error {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if mode, ok := cs.modeIndex[modeName]; !ok {
+		return ErrNoMatch
+	} else {
+		// Copy so anyone still iterating the old slice is unaffected
+		commands := make([]*Command, 0, len(mode.Commands)+1)
+		mode.Commands = append(append(commands, mode.Commands...), cmd)
+		return nil
+	}
+}
+
+// RemoveCommand removes the named command from the named mode
+func (cs *Shell) RemoveCommand(modeName string, cmdName string) error {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	mode, ok := cs.modeIndex[modeName]
+	if !ok {
+		return ErrNoMatch
+	}
+	for i, c := range mode.Commands {
+		if c.Name == cmdName {
+			commands := make([]*Command, 0, len(mode.Commands)-1)
+			commands = append(commands, mode.Commands[:i]...)
+			mode.Commands = append(commands, mode.Commands[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNoMatch
+}
+
+// resolve finds the command and builds its middleware chain while holding
+// the lock, the returned handler is run without it
+func (cs *Shell) resolve(name string, inv *Invocation) (*Command, *CommandMode, CommandHandler, error) {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	if cmd, mode, err := cs.Mode.Resolve(name); err != nil {
+		return nil, nil, nil, err
+	} else {
+		return cmd, mode, chainMiddleware(cmd, mode, inv, cs.middleware, mode.Middleware), nil
+	}
+}
+
+// wantsHelp is true when --help is the first argument, anywhere else it is
+// left for the handler
+func wantsHelp(args []string) bool {
+	return len(args) > 0 && args[0] == "--help"
+}
+
+// RunCommand runs a command with the shell's streams
 func (cs *Shell) RunCommand(parsed []string) error {
+	return cs.invoke(parsed, cs.newInvocation())
+}
+
+// invoke runs a command with the invocation's streams, filling in the rest
+// of the invocation once the command has been found
+func (cs *Shell) invoke(parsed []string, inv *Invocation) error {
 	if cs.Echo {
-		cs.Printf("%s\n", strings.Join(parsed, " "))
+		cs.outLock.Lock()
+		cs.writeLocked(strings.Join(parsed, " ") + "\n")
+		cs.outLock.Unlock()
 	}
-	if cmd, err := cs.Mode.Match(parsed[0]); err != nil {
+	if cmd, mode, handler, err := cs.resolve(parsed[0], inv); err != nil {
 		return err
 	} else {
-		return cmd.Run(parsed[1:])
+		args, format, ok := outputOption(parsed[1:])
+		if ok {
+			inv.Format = format
+		}
+		inv.Command = cmd
+		inv.Mode = mode
+		inv.Args = args
+
+		if pw := cs.startPager(cmd, inv); pw != nil {
+			defer cs.finishPager(pw)
+		}
+		defer cs.redirect(inv)()
+
+		if wantsHelp(args) {
+			inv.Printf("%s", formatCommandHelp(cmd, mode, cs.width(), inv.Theme()))
+			return nil
+		}
+		if len(cmd.Deprecated) > 0 {
+			warning := fmt.Sprintf("WARNING: %s is deprecated, %s", parsed[0], cmd.Deprecated)
+			if _, err := fmt.Fprintln(inv.Stderr, cs.themeFor(cs.Err).Warning.Apply(warning)); err != nil {
+				log.Println("Unable to write to error stream", err)
+			}
+		}
+		return handler(args)
 	}
 }
@@ -18,8 +18,10 @@ package shell_test
 import (
 	"github.com/threeguys/golang-ezshell/shell"
 	"github.com/threeguys/golang-toolkit/objects"
+	"io"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -28,6 +30,8 @@ func TestNewCommandShell(t *testing.T) {
 	cs := shell.NewCommandShell("foo", []*shell.Command{})
 	assert.NotNil(cs)
 	assert.Equal(cs.Out, os.Stdout)
+	assert.Equal(cs.Err, os.Stderr)
+	assert.Equal(cs.In, os.Stdin)
 	assert.False(cs.Echo)
 	assert.Equal(cs.Global, cs.Mode)
 	assert.Equal(0, len(cs.Global.Commands))
@@ -105,3 +109,102 @@ func TestNewCommandShell_Help(t *testing.T) {
 	resetTempFile(t, out)
 	assert.False(strings.EqualFold("", strings.TrimSpace(getLogData(t, out))))
 }
+
+func TestShell_AddMode(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := shell.NewCommandShell("boh", []*shell.Command{})
+	mode := &shell.CommandMode{ Name: "added", Description: "added later" }
+
+	assert.Nil(cs.AddMode(mode))
+	assert.Equal(cs.Global, mode.Delegate)
+	assert.Equal(shell.ErrModeExists, cs.AddMode(mode))
+	assert.Nil(cs.SwitchMode("added"))
+	assert.Equal(mode, cs.CurrentMode())
+}
+
+func TestShell_AddRemoveCommand(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := shell.NewCommandShell("boh", []*shell.Command{})
+	bazCmd := &shell.Command{ Name: "baz", Handler: shell.NoOpHandler() }
+
+	assert.Nil(cs.AddCommand("global", bazCmd))
+	assert.Equal(1, len(cs.Global.Commands))
+	assert.Nil(cs.RunCommand([]string{ "baz" }))
+	assert.Equal(shell.ErrNoMatch, cs.AddCommand("nope", bazCmd))
+
+	assert.Nil(cs.RemoveCommand("global", "baz"))
+	assert.Equal(0, len(cs.Global.Commands))
+	assert.Equal(shell.ErrNoMatch, cs.RemoveCommand("global", "baz"))
+	assert.Equal(shell.ErrNoMatch, cs.RunCommand([]string{ "baz" }))
+}
+
+func TestShell_ConcurrentPrint(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	modeA := &shell.CommandMode{ Name: "a" }
+	modeB := &shell.CommandMode{ Name: "b" }
+	cs := shell.NewCommandShell("boh", []*shell.Command{}, modeA, modeB)
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+
+	line := strings.Repeat("x", 512)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				cs.Printf("%d:%s\n", i, line)
+				if i % 2 == 0 {
+					_ = cs.SwitchMode("a")
+				} else {
+					_ = cs.SwitchMode("b")
+				}
+				_ = cs.CurrentMode()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(getLogData(t, out)), "\n")
+	assert.Equal(400, len(lines))
+	for _, l := range lines {
+		assert.True(strings.HasSuffix(l, ":" + line))
+	}
+}
+
+type partialSupplier struct {
+	cs *shell.Shell
+	lines [][]string
+}
+
+func (ps *partialSupplier) PartialLine() string {
+	return "half-typ"
+}
+
+func (ps *partialSupplier) Read() ([]string, error) {
+	if len(ps.lines) == 0 {
+		return nil, io.EOF
+	}
+	ps.cs.AsyncPrintf("background %s", "done")
+	line := ps.lines[0]
+	ps.lines = ps.lines[1:]
+	return line, nil
+}
+
+func TestShell_AsyncPrintf(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs := shell.NewCommandShell("$ ", []*shell.Command{
+		{ Name: "noop", Handler: shell.NoOpHandler() },
+	})
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+
+	// Not prompting, so it's just printed
+	cs.AsyncPrintf("before")
+	_ = cs.RunSupplier(&partialSupplier{ cs: cs, lines: [][]string{ { "noop" } } })
+
+	expected := "before\n$ \r\x1b[Kbackground done\n$ half-typ$ "
+	assert.Equal(expected, getLogData(t, out))
+}
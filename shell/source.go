@@ -0,0 +1,90 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"github.com/threeguys/golang-ezshell/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	MaxSourceDepth = 16
+)
+
+var (
+	ErrSourceDepth = errors.New("scripts nested too deeply")
+)
+
+// expandHome replaces a leading ~/ with the user's home directory
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+// Source runs the script in the current session, so changes it makes to the
+// shell such as switching modes are kept once it finishes
+func (cs *Shell) Source(path string) error {
+	if cs.sourceDepth >= MaxSourceDepth {
+		return ErrSourceDepth
+	}
+
+	f, err := os.Open(expandHome(path))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	quiet := cs.Quiet
+	cs.Quiet = true
+	cs.sourceDepth++
+	defer func() {
+		cs.sourceDepth--
+		cs.Quiet = quiet
+	}()
+
+	if err := cs.runSupplier(parser.NewCommandReader(f)); err != nil {
+		if _, ok := asExitError(err); ok {
+			return err
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// loadRcFile sources RcFile if it is set and the file exists
+func (cs *Shell) loadRcFile() error {
+	if len(cs.RcFile) == 0 {
+		return nil
+	} else if _, err := os.Stat(expandHome(cs.RcFile)); os.IsNotExist(err) {
+		return nil
+	}
+	return cs.Source(cs.RcFile)
+}
+
+func (cs *Shell) builtinSource(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: source <file>")
+	}
+	return cs.Source(args[0])
+}
@@ -0,0 +1,114 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"errors"
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTempScript(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal("Could not write script", err)
+	}
+	return path
+}
+
+func TestShell_Source(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	dir := t.TempDir()
+	inner := writeTempScript(t, dir, "inner.ez", "noop\n")
+	outer := writeTempScript(t, dir, "outer.ez", "source " + inner + "\nset -e\n")
+
+	cs := createMockTestShell()
+	cs.Echo = false
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+
+	assert.Nil(cs.RunCommand([]string{ "source", outer }))
+	assert.Equal(shell.StopOnError, cs.ErrorPolicy)
+	assert.False(cs.Quiet)
+	assert.Equal("SUCCESS\n", getLogData(t, out))
+}
+
+func TestShell_Source_Errors(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	dir := t.TempDir()
+	loop := filepath.Join(dir, "loop.ez")
+	writeTempScript(t, dir, "loop.ez", "source " + loop + "\n")
+	failing := writeTempScript(t, dir, "fail.ez", "set -e\nerr\nnoop\n")
+	exiting := writeTempScript(t, dir, "exit.ez", "exit 9\nnoop\n")
+
+	cs := createMockTestShell()
+	cs.Echo = false
+	cs.Quiet = true
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+	cs.Err = out
+
+	// Only the innermost script fails, which is just reported unless set -e
+	assert.Nil(cs.Source(loop))
+	assert.Equal("ERROR: scripts nested too deeply\n", getLogData(t, out))
+	cs.ErrorPolicy = shell.StopOnError
+	assert.True(errors.Is(cs.Source(loop), shell.ErrSourceDepth))
+	cs.ErrorPolicy = shell.ContinueOnError
+
+	assert.True(os.IsNotExist(cs.Source(filepath.Join(dir, "missing.ez"))))
+
+	err := cs.Source(failing)
+	var se *shell.ScriptError
+	assert.True(errors.As(err, &se))
+	assert.Equal(2, se.Line)
+
+	assert.Equal(shell.Exit(9), cs.Source(exiting))
+}
+
+func TestShell_RcFile(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	dir := t.TempDir()
+
+	cs := createMockTestShell()
+	cs.Echo = false
+	cs.Quiet = true
+	cs.RcFile = writeTempScript(t, dir, "rc", "noop\n")
+	out := makeTempLog(t)
+	defer func() { assert.Nil(out.Close()) }()
+	cs.Out = out
+
+	in := makeTempLog(t)
+	defer func() { assert.Nil(in.Close()) }()
+	_, err := in.WriteString("noop\n")
+	assert.Nil(err)
+	resetTempFile(t, in)
+
+	cs.In = in
+
+	assert.Nil(cs.Run())
+	assert.Equal("SUCCESS\nSUCCESS\n", getLogData(t, out))
+
+	// A missing rc file is fine
+	cs.RcFile = filepath.Join(dir, "not-there")
+	resetTempFile(t, in)
+	assert.Nil(cs.Run())
+}
@@ -0,0 +1,226 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"github.com/threeguys/golang-ezshell/terminal"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownStyle = errors.New("unknown style")
+)
+
+// Style is a list of ANSI SGR parameters such as "1;31" for bold red, the
+// empty style leaves text alone
+type Style string
+
+// Apply wraps the text in the style's escape codes
+func (s Style) Apply(text string) string {
+	if len(s) == 0 || len(text) == 0 {
+		return text
+	}
+	return "\x1b[" + string(s) + "m" + text + "\x1b[0m"
+}
+
+var styleNames = map[string]int{
+	"bold": 1, "dim": 2, "italic": 3, "underline": 4, "reverse": 7,
+	"black": 30, "red": 31, "green": 32, "yellow": 33, "blue": 34, "magenta": 35, "cyan": 36, "white": 37,
+}
+
+// ParseStyle builds a style from words like "bold red" or "underline
+// bright-blue on-black", SGR numbers can be used as well
+func ParseStyle(spec string) (Style, error) {
+	codes := make([]string, 0)
+	for _, word := range strings.Fields(strings.ToLower(spec)) {
+		offset := 0
+		name := word
+		if strings.HasPrefix(name, "on-") {
+			offset += 10
+			name = name[3:]
+		}
+		if strings.HasPrefix(name, "bright-") {
+			offset += 60
+			name = name[7:]
+		}
+
+		if code, ok := styleNames[name]; ok && (offset == 0 || code >= 30) {
+			codes = append(codes, strconv.Itoa(code + offset))
+		} else if _, err := strconv.Atoi(word); err == nil {
+			codes = append(codes, word)
+		} else if word != "none" && word != "plain" {
+			return "", fmt.Errorf("%w [%s]", ErrUnknownStyle, word)
+		}
+	}
+	return Style(strings.Join(codes, ";")), nil
+}
+
+// Theme is the style of each part of the shell's output. The last few are
+// only used to highlight the line being typed.
+type Theme struct {
+	Prompt      Style
+	Error       Style
+	Warning     Style
+	Heading     Style
+	Command     Style
+	TableHeader Style
+
+	Unknown  Style // a command which doesn't exist
+	Keyword  Style
+	String   Style
+	Variable Style
+	Operator Style
+	Suggestion Style
+}
+
+// DefaultTheme is the theme new shells start with
+var DefaultTheme = Theme{
+	Prompt:      "1;32",
+	Error:       "1;31",
+	Warning:     "33",
+	Heading:     "1",
+	Command:     "36",
+	TableHeader: "1;4",
+	Unknown:     "31",
+	Keyword:     "35",
+	String:      "33",
+	Variable:    "32",
+	Operator:    "1",
+	Suggestion:  "2",
+}
+
+// Styles maps the names used by the theme command to the theme's styles
+func (t *Theme) Styles() map[string]*Style {
+	return map[string]*Style{
+		"prompt":  &t.Prompt,
+		"error":   &t.Error,
+		"warning": &t.Warning,
+		"heading": &t.Heading,
+		"command": &t.Command,
+		"header":  &t.TableHeader,
+		"unknown":  &t.Unknown,
+		"keyword":  &t.Keyword,
+		"string":   &t.String,
+		"variable": &t.Variable,
+		"operator": &t.Operator,
+		"suggestion": &t.Suggestion,
+	}
+}
+
+// ColorMode says when the shell's Theme is used
+type ColorMode int
+
+const (
+	ColorAuto ColorMode = iota // when writing to a terminal and NO_COLOR isn't set
+	ColorAlways
+	ColorNever
+)
+
+// colorEnabled reports whether output to w should be styled
+func (cs *Shell) colorEnabled(w io.Writer) bool {
+	switch cs.Color {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if len(os.Getenv("NO_COLOR")) > 0 {
+		return false
+	}
+	fd, ok := terminal.Fd(w)
+	return ok && terminal.IsTerminal(fd)
+}
+
+// themeFor returns the shell's theme when output to w should be styled,
+// otherwise the empty theme which leaves everything plain
+func (cs *Shell) themeFor(w io.Writer) Theme {
+	if cs.colorEnabled(w) {
+		cs.lock.RLock()
+		defer cs.lock.RUnlock()
+		return cs.Theme
+	}
+	return Theme{}
+}
+
+// builtinTheme lists the theme's styles, or changes one of them
+func (cs *Shell) builtinTheme(inv *Invocation) error {
+	if len(inv.Args) == 0 {
+		// Copy the styles so nothing is locked while printing
+		cs.lock.RLock()
+		theme := cs.Theme
+		cs.lock.RUnlock()
+		styles := theme.Styles()
+		names := themeElements()
+		width := 0
+		for _, name := range names {
+			if len(name) > width {
+				width = len(name)
+			}
+		}
+		for _, name := range names {
+			if inv.color {
+				inv.Printf("%-*s %s\n", width, name, styles[name].Apply(string(*styles[name])))
+			} else {
+				inv.Printf("%-*s %s\n", width, name, *styles[name])
+			}
+		}
+		return nil
+	}
+
+	if _, ok := (&Theme{}).Styles()[inv.Args[0]]; !ok {
+		return fmt.Errorf("%w [%s]", ErrUnknownStyle, inv.Args[0])
+	}
+	parsed, err := ParseStyle(strings.Join(inv.Args[1:], " "))
+	if err != nil {
+		return err
+	}
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	*cs.Theme.Styles()[inv.Args[0]] = parsed
+	return nil
+}
+
+// themeElements is the sorted names of the theme's styles
+func themeElements() []string {
+	names := make([]string, 0)
+	for name := range (&Theme{}).Styles() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// themeCompleter completes the element names and then the style words
+func themeCompleter(args []string) []string {
+	if len(args) == 0 {
+		return themeElements()
+	}
+	words := []string{ "none" }
+	for name, code := range styleNames {
+		words = append(words, name)
+		if code >= 30 {
+			words = append(words, "bright-" + name, "on-" + name)
+		}
+	}
+	sort.Strings(words)
+	return words
+}
@@ -0,0 +1,120 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"errors"
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"strings"
+	"testing"
+)
+
+func TestParseStyle(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+
+	style, err := shell.ParseStyle("Bold red")
+	assert.Nil(err)
+	assert.Equal(shell.Style("1;31"), style)
+	style, err = shell.ParseStyle("bright-blue on-black 38")
+	assert.Nil(err)
+	assert.Equal(shell.Style("94;40;38"), style)
+	style, err = shell.ParseStyle("none")
+	assert.Nil(err)
+	assert.Equal(shell.Style(""), style)
+
+	_, err = shell.ParseStyle("sparkly")
+	assert.True(errors.Is(err, shell.ErrUnknownStyle))
+	_, err = shell.ParseStyle("on-bold")
+	assert.True(errors.Is(err, shell.ErrUnknownStyle))
+}
+
+func TestStyle_Apply(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	assert.Equal("\x1b[1;31mhi\x1b[0m", shell.Style("1;31").Apply("hi"))
+	assert.Equal("hi", shell.Style("").Apply("hi"))
+	assert.Equal("", shell.Style("1").Apply(""))
+}
+
+func TestShell_Theme(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs, out := createPipelineShell()
+	assert.Nil(cs.AddCommand("global", &shell.Command{
+		Name:   "styled",
+		Invoke: func(inv *shell.Invocation) error {
+			inv.Println(inv.Styled("1", "bold"), inv.Theme().Command.Apply("cmd"))
+			return nil
+		},
+	}))
+
+	// Nothing is styled when the output isn't a terminal
+	cs.In = strings.NewReader("styled\nbogus\n")
+	assert.Nil(cs.Run())
+	assert.Equal("bold cmd\nERROR: no matching command found\n", out.String())
+
+	out.Reset()
+	cs.Color = shell.ColorAlways
+	cs.In = strings.NewReader("styled\nbogus\nhosts | head -1\nhosts | grep db1\ntheme error underline\ntheme header none\nbogus\nhosts | head -1\n")
+	assert.Nil(cs.Run())
+	assert.Equal("\x1b[1mbold\x1b[0m \x1b[36mcmd\x1b[0m\n" +
+		"\x1b[1;31mERROR: no matching command found\x1b[0m\n" +
+		"\x1b[1;4mNAME  UP    LOAD  TAGS\x1b[0m\n" +
+		"web1  true  0.5   map[role:web]\n" +
+		"db1   false  12    map[role:db]\n" +
+		"\x1b[4mERROR: no matching command found\x1b[0m\n" +
+		"NAME  UP    LOAD  TAGS\n" +
+		"web1  true  0.5   map[role:web]\n", out.String())
+	assert.Equal(shell.Style("4"), cs.Theme.Error)
+
+	out.Reset()
+	cs.In = strings.NewReader("theme\ntheme sparkle red\n")
+	assert.Nil(cs.Run())
+	assert.True(strings.HasPrefix(out.String(), "command    \x1b[36m36\x1b[0m\nerror      \x1b[4m4\x1b[0m\nheader     \n"))
+	assert.True(strings.Contains(out.String(), "\nsuggestion \x1b[2m2\x1b[0m\n"))
+	assert.True(strings.HasSuffix(out.String(), "ERROR: unknown style [sparkle]\x1b[0m\n"))
+}
+
+// lookupWriter reads a variable on every write, like a logging writer might
+type lookupWriter struct {
+	cs *shell.Shell
+	out *strings.Builder
+}
+
+func (lw lookupWriter) Write(p []byte) (int, error) {
+	lw.cs.Var("PATH")
+	return lw.out.Write(p)
+}
+
+func TestShell_Theme_NoLockWhilePrinting(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs, _ := createPipelineShell()
+	out := &strings.Builder{}
+	cs.Out = lookupWriter{ cs, out }
+
+	assert.Nil(cs.RunCommand([]string{ "theme" }))
+	assert.True(strings.Contains(out.String(), "suggestion 2\n"))
+	assert.Nil(cs.RunCommand([]string{ "theme", "suggestion", "bold" }))
+	assert.Equal(shell.Style("1"), cs.Theme.Suggestion)
+}
+
+func TestShell_Theme_Help(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs, out := createPipelineShell()
+	assert.Nil(cs.RunCommand([]string{ "help", "theme" }))
+	for name := range cs.Theme.Styles() {
+		assert.True(strings.Contains(out.String(), name))
+	}
+}
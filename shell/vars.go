@@ -0,0 +1,187 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell
+
+import (
+	"github.com/threeguys/golang-ezshell/parser"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// IsVarName reports whether name can be used as a variable name
+func IsVarName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isNameChar(name[i]) || (i == 0 && name[i] >= '0' && name[i] <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// SetVar sets a shell variable, scripts expand it with $name or ${name}
+func (cs *Shell) SetVar(name string, value string) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if cs.vars == nil {
+		cs.vars = make(map[string]string)
+	}
+	cs.vars[name] = value
+}
+
+// UnsetVar removes a shell variable
+func (cs *Shell) UnsetVar(name string) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	delete(cs.vars, name)
+}
+
+// Vars returns a copy of the shell's variables, without the environment
+func (cs *Shell) Vars() map[string]string {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	vars := make(map[string]string, len(cs.vars))
+	for name, value := range cs.vars {
+		vars[name] = value
+	}
+	return vars
+}
+
+// Var returns the value of a shell variable, falling back to the environment
+// when the shell doesn't have one with that name
+func (cs *Shell) Var(name string) (string, bool) {
+	cs.lock.RLock()
+	value, ok := cs.vars[name]
+	cs.lock.RUnlock()
+	if ok {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+// lookup resolves the special variables for function arguments before
+// falling back to Var
+func (cs *Shell) lookup(name string) string {
+	switch name {
+	case "?":
+		return strconv.Itoa(cs.LastStatus())
+	case "#":
+		return strconv.Itoa(len(cs.positional))
+	case "@":
+		return strings.Join(cs.positional, " ")
+	}
+
+	if n, err := strconv.Atoi(name); err == nil {
+		if n > 0 && n <= len(cs.positional) {
+			return cs.positional[n-1]
+		}
+		return ""
+	}
+
+	value, _ := cs.Var(name)
+	return value
+}
+
+// expand substitutes the variables in text, it reports whether anything
+// was substituted. A $ which doesn't start a variable is left alone.
+func (cs *Shell) expand(text string) (string, bool) {
+	if !strings.Contains(text, "$") {
+		return text, false
+	}
+
+	var sb strings.Builder
+	expanded := false
+	for i := 0; i < len(text); {
+		name, end := variableAt(text, i)
+		if len(name) == 0 {
+			sb.WriteByte(text[i])
+			i++
+		} else {
+			sb.WriteString(cs.lookup(name))
+			expanded = true
+			i = end
+		}
+	}
+	return sb.String(), expanded
+}
+
+// variableAt returns the name of the variable referenced by a $ at i and
+// the index just past the reference, the name is empty if there isn't one
+func variableAt(text string, i int) (string, int) {
+	if text[i] != '$' || i+1 >= len(text) {
+		return "", i + 1
+	}
+
+	next := text[i+1]
+	switch {
+	case next == '{':
+		if end := strings.IndexByte(text[i+2:], '}'); end > 0 {
+			return text[i+2:i+2+end], i + 3 + end
+		}
+	case next == '?' || next == '#' || next == '@' || (next >= '0' && next <= '9'):
+		return text[i+1:i+2], i + 2
+	case isNameChar(next):
+		end := i + 1
+		for end < len(text) && isNameChar(text[end]) {
+			end++
+		}
+		return text[i+1:end], end
+	}
+	return "", i + 1
+}
+
+// expandTokens turns tokens into the words of a command. Words in single
+// quotes are taken literally, and an unquoted word which had a variable in it
+// is split on whitespace the way a POSIX shell would.
+func (cs *Shell) expandTokens(tokens []parser.Token) []string {
+	words := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		if t.Kind != parser.TokenWord {
+			continue
+		} else if t.Quote == '\'' {
+			if len(t.Text) > 0 {
+				words = append(words, t.Text)
+			}
+		} else if value, expanded := cs.expand(t.Text); !expanded {
+			if len(value) > 0 {
+				words = append(words, value)
+			}
+		} else if t.Quote == 0 {
+			words = append(words, strings.Fields(value)...)
+		} else {
+			words = append(words, value)
+		}
+	}
+	return words
+}
+
+// assignment reports whether the tokens are a single name=value word
+func assignment(tokens []parser.Token) (string, string, bool) {
+	if len(tokens) != 1 || tokens[0].Quote != 0 {
+		return "", "", false
+	}
+	if eq := strings.IndexByte(tokens[0].Text, '='); eq > 0 && IsVarName(tokens[0].Text[:eq]) {
+		return tokens[0].Text[:eq], tokens[0].Text[eq+1:], true
+	}
+	return "", "", false
+}
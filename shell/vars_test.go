@@ -0,0 +1,75 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package shell_test
+
+import (
+	"github.com/threeguys/golang-ezshell/shell"
+	"github.com/threeguys/golang-toolkit/objects"
+	"os"
+	"testing"
+)
+
+func TestIsVarName(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	assert.True(shell.IsVarName("name"))
+	assert.True(shell.IsVarName("_x1"))
+	assert.False(shell.IsVarName(""))
+	assert.False(shell.IsVarName("1x"))
+	assert.False(shell.IsVarName("a-b"))
+}
+
+func TestShell_Vars(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	cs, out := createScriptShell(t)
+	defer func() { assert.Nil(out.Close()) }()
+
+	_, ok := cs.Var("EZSHELL_TEST_VAR")
+	assert.False(ok)
+	assert.Nil(os.Setenv("EZSHELL_TEST_VAR", "from-env"))
+	defer func() { assert.Nil(os.Unsetenv("EZSHELL_TEST_VAR")) }()
+	value, ok := cs.Var("EZSHELL_TEST_VAR")
+	assert.True(ok)
+	assert.Equal("from-env", value)
+
+	cs.SetVar("EZSHELL_TEST_VAR", "from-shell")
+	value, _ = cs.Var("EZSHELL_TEST_VAR")
+	assert.Equal("from-shell", value)
+	cs.UnsetVar("EZSHELL_TEST_VAR")
+	value, _ = cs.Var("EZSHELL_TEST_VAR")
+	assert.Equal("from-env", value)
+
+	assert.Nil(cs.RunCommand([]string{ "set", "greeting=hello there", "-e" }))
+	value, _ = cs.Var("greeting")
+	assert.Equal("hello there", value)
+	assert.Equal(shell.StopOnError, cs.ErrorPolicy)
+	assert.NotNil(cs.RunCommand([]string{ "set", "1x=bad" }))
+
+	assert.Nil(cs.RunCommand([]string{ "unset", "greeting" }))
+	_, ok = cs.Var("greeting")
+	assert.False(ok)
+}
+
+func TestShell_Expansion(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+
+	out, err := runScriptText(t, `
+name=world
+echo $name ${name}s "$name's" '$name' $ cost$ $1 [$missing]
+echo "a  b" a${missing}b "${name"
+`)
+	assert.Nil(err)
+	assert.Equal("world worlds world's $name $ cost$ []\na  b ab ${name\n", out)
+}
@@ -0,0 +1,667 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package terminal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	keyRune = iota
+	keyEnter
+	keyEscape
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+	keyPageUp
+	keyPageDown
+	keyUnknown
+)
+
+const (
+	ctrlA = 0x01
+	ctrlB = 0x02
+	ctrlC = 0x03
+	ctrlD = 0x04
+	ctrlE = 0x05
+	ctrlF = 0x06
+	ctrlH = 0x08
+	ctrlK = 0x0b
+	ctrlL = 0x0c
+	ctrlN = 0x0e
+	ctrlP = 0x10
+	ctrlU = 0x15
+	ctrlW = 0x17
+	tab = 0x09
+	backspace = 0x7f
+	escape = 0x1b
+)
+
+var (
+	// ErrInterrupted is returned by ReadLine when ctrl-c throws the line away
+	ErrInterrupted = errors.New("interrupted")
+)
+
+type key struct {
+	code int
+	r rune
+	alt bool
+}
+
+// LineEditor reads a line at a time from a terminal with emacs style editing
+// keys, history, tab completion and inline help on '?'
+type LineEditor struct {
+	in *bufio.Reader
+	out io.Writer
+	file *os.File
+
+	Prompt string
+	History []string
+
+	// Help is called when '?' is typed outside of quotes with the text before
+	// the cursor, what it returns is printed below the line
+	Help func(line string) string
+
+	// Complete is called on tab with the text before the cursor, it returns
+	// the possible replacements for the last word
+	Complete func(line string) []string
+
+	// Highlight is called with the whole line each time it is drawn, it
+	// returns the line with color escape codes added and nothing else changed
+	Highlight func(line string) string
+
+	// Autosuggest shows the rest of the newest history entry starting with
+	// the line, or the only completion of the last word, after the cursor
+	// in SuggestionStyle (SGR parameters like "2" for dim). Right arrow or
+	// ctrl-f accepts all of it and alt-f the next word.
+	Autosuggest bool
+	SuggestionStyle string
+
+	// Keymap picks emacs style editing or vi's insert and normal modes
+	Keymap Keymap
+
+	lock sync.Mutex
+	line []rune
+	pos int
+	reading bool
+	histIndex int
+	pending []rune
+	queued []string
+	lastTab bool
+	raw *State
+	vi viState
+
+	// the last suggestion and the line and cursor it was made for
+	suggested string
+	suggestedFor string
+	suggestedPos int
+}
+
+// NewLineEditor creates an editor for the reader and writer, the caller is
+// responsible for any terminal settings
+func NewLineEditor(in io.Reader, out io.Writer) *LineEditor {
+	return &LineEditor{
+		in:      bufio.NewReader(in),
+		out:     out,
+		History: make([]string, 0),
+		SuggestionStyle: "2",
+	}
+}
+
+// NewTerminalEditor creates an editor which switches the terminal to raw
+// mode while it is reading a line
+func NewTerminalEditor(in *os.File, out io.Writer) *LineEditor {
+	le := NewLineEditor(in, out)
+	le.file = in
+	return le
+}
+
+func (le *LineEditor) write(out string) {
+	if _, err := io.WriteString(le.out, out); err != nil {
+		log.Println("Unable to write to terminal", err)
+	}
+}
+
+// crlf converts line feeds for output while the terminal is in raw mode
+func crlf(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+}
+
+// Buffer returns what has been typed on the current line so far
+func (le *LineEditor) Buffer() string {
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	return string(le.line)
+}
+
+// PrintAbove prints the message above the line being edited and redraws it,
+// it is safe to call from other goroutines while ReadLine is running
+func (le *LineEditor) PrintAbove(msg string) {
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	if !le.reading {
+		le.write(msg)
+		return
+	}
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	le.write("\r\x1b[K" + crlf(msg))
+	le.refreshLocked()
+}
+
+// AddHistory appends the line to the history unless it is blank or the same
+// as the most recent entry
+func (le *LineEditor) AddHistory(line string) {
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	le.addHistoryLocked(line)
+}
+
+func (le *LineEditor) addHistoryLocked(line string) {
+	if len(strings.TrimSpace(line)) == 0 {
+		return
+	} else if len(le.History) > 0 && le.History[len(le.History)-1] == line {
+		return
+	}
+	le.History = append(le.History, line)
+}
+
+// ReadLine shows the prompt and returns the line once enter is pressed, at the
+// start of an empty line ctrl-d returns io.EOF and ctrl-c returns
+// ErrInterrupted anywhere
+func (le *LineEditor) ReadLine() (string, error) {
+	le.raw = nil
+	if le.file != nil {
+		if state, err := MakeRaw(le.file.Fd()); err == nil {
+			le.raw = state
+			defer func() {
+				if err := Restore(le.file.Fd(), state); err != nil {
+					log.Println("Unable to restore terminal", err)
+				}
+			}()
+		}
+	}
+
+	le.lock.Lock()
+	le.vi.reset()
+	le.line = make([]rune, 0)
+	le.pos = 0
+	le.reading = true
+	le.histIndex = len(le.History)
+	le.lastTab = false
+	le.suggestedFor = ""
+	le.refreshLocked()
+	le.lock.Unlock()
+
+	defer func() {
+		le.lock.Lock()
+		le.reading = false
+		le.lock.Unlock()
+	}()
+	if line, ok := le.readQueued(); ok {
+		return line, nil
+	}
+
+	for {
+		k, err := readKey(le.in)
+		if err == io.EOF && len(le.line) > 0 {
+			k = key{ code: keyEnter }
+		} else if err != nil {
+			return "", err
+		}
+
+		le.lock.Lock()
+		var line string
+		var done bool
+		if le.Keymap == ViKeymap {
+			line, done, err = le.handleViKey(k)
+		} else {
+			line, done, err = le.handleKey(k)
+		}
+		edit := le.vi.edit
+		le.vi.edit = false
+		le.lock.Unlock()
+		if edit {
+			line, done, err = le.runEditor()
+		}
+		if done {
+			return line, err
+		}
+	}
+}
+
+// readQueued returns the next of the lines saved in the external editor,
+// shown after the prompt as if it had been typed
+func (le *LineEditor) readQueued() (string, bool) {
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	if len(le.queued) == 0 {
+		return "", false
+	}
+	le.line = []rune(le.queued[0])
+	le.queued = le.queued[1:]
+	le.pos = len(le.line)
+	le.refreshLocked()
+	line, _, _ := le.handleKey(key{ code: keyEnter })
+	return line, true
+}
+
+// readKey reads a key press, turning escape sequences into their keys
+func readKey(in *bufio.Reader) (key, error) {
+	r, _, err := in.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+
+	switch r {
+	case '\r', '\n':
+		return key{ code: keyEnter }, nil
+	case escape:
+		// Escape sequences arrive in one read, so a lone escape has nothing behind it
+		if in.Buffered() == 0 {
+			return key{ code: keyEscape }, nil
+		}
+		return readEscape(in)
+	default:
+		return key{ code: keyRune, r: r }, nil
+	}
+}
+
+func readEscape(in *bufio.Reader) (key, error) {
+	r, _, err := in.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+
+	switch r {
+	case '[':
+		seq := make([]rune, 0)
+		for {
+			c, _, err := in.ReadRune()
+			if err != nil {
+				return key{}, err
+			}
+			seq = append(seq, c)
+			if c >= 0x40 && c <= 0x7e {
+				break
+			}
+		}
+		return escapeKey(string(seq)), nil
+	case 'O':
+		c, _, err := in.ReadRune()
+		if err != nil {
+			return key{}, err
+		}
+		return escapeKey(string(c)), nil
+	default:
+		return key{ code: keyRune, r: r, alt: true }, nil
+	}
+}
+
+func escapeKey(seq string) key {
+	switch seq {
+	case "A":
+		return key{ code: keyUp }
+	case "B":
+		return key{ code: keyDown }
+	case "C":
+		return key{ code: keyRight }
+	case "D":
+		return key{ code: keyLeft }
+	case "H", "1~", "7~":
+		return key{ code: keyHome }
+	case "F", "4~", "8~":
+		return key{ code: keyEnd }
+	case "3~":
+		return key{ code: keyDelete }
+	case "5~":
+		return key{ code: keyPageUp }
+	case "6~":
+		return key{ code: keyPageDown }
+	default:
+		return key{ code: keyUnknown }
+	}
+}
+
+// handleKey applies the key to the line, returning the line and true when
+// ReadLine should return
+func (le *LineEditor) handleKey(k key) (string, bool, error) {
+	tabbed := false
+	defer func() { le.lastTab = tabbed }()
+
+	switch k.code {
+	case keyEnter:
+		line := string(le.line)
+		le.clearSuggestion()
+		le.write("\r\n")
+		le.addHistoryLocked(line)
+		return line, true, nil
+	case keyUp:
+		le.historyMove(-1)
+	case keyDown:
+		le.historyMove(1)
+	case keyLeft:
+		le.moveTo(le.pos - 1)
+	case keyRight:
+		le.acceptSuggestion(false)
+	case keyHome:
+		le.moveTo(0)
+	case keyEnd:
+		le.moveTo(len(le.line))
+	case keyDelete:
+		le.deleteRange(le.pos, le.pos + 1)
+	case keyRune:
+		if k.alt {
+			if k.r == 'f' {
+				le.acceptSuggestion(true)
+			}
+			break
+		}
+		switch k.r {
+		case ctrlA:
+			le.moveTo(0)
+		case ctrlE:
+			le.moveTo(len(le.line))
+		case ctrlB:
+			le.moveTo(le.pos - 1)
+		case ctrlF:
+			le.acceptSuggestion(false)
+		case ctrlP:
+			le.historyMove(-1)
+		case ctrlN:
+			le.historyMove(1)
+		case ctrlH, backspace:
+			if le.pos > 0 {
+				le.deleteRange(le.pos - 1, le.pos)
+			}
+		case ctrlK:
+			le.deleteRange(le.pos, len(le.line))
+		case ctrlU:
+			le.deleteRange(0, le.pos)
+		case ctrlW:
+			le.deleteRange(wordStart(le.line, le.pos), le.pos)
+		case ctrlL:
+			le.write("\x1b[H\x1b[2J")
+			le.refreshLocked()
+		case ctrlC:
+			le.clearSuggestion()
+			le.write("^C\r\n")
+			return "", true, ErrInterrupted
+		case ctrlD:
+			if len(le.line) == 0 {
+				le.write("\r\n")
+				return "", true, io.EOF
+			}
+			le.deleteRange(le.pos, le.pos + 1)
+		case tab:
+			tabbed = le.complete()
+		case '?':
+			if le.Help != nil && !inQuotes(le.line[:le.pos]) {
+				le.showBelow(le.Help(string(le.line[:le.pos])))
+			} else {
+				le.insert(k.r)
+			}
+		default:
+			if k.r >= ' ' {
+				le.insert(k.r)
+			}
+		}
+	}
+	return "", false, nil
+}
+
+// refreshLocked redraws the prompt and line, leaving the cursor in place
+func (le *LineEditor) refreshLocked() {
+	line := string(le.line)
+	if le.Highlight != nil {
+		line = le.Highlight(line)
+	}
+	buf := "\r" + le.Prompt + line + "\x1b[K"
+	back := len(le.line) - le.pos
+	if suggestion := le.suggestion(); len(suggestion) > 0 {
+		buf += "\x1b[" + le.SuggestionStyle + "m" + suggestion + "\x1b[0m"
+		back = utf8.RuneCountInString(suggestion)
+	}
+	if back > 0 {
+		buf += fmt.Sprintf("\x1b[%dD", back)
+	}
+	le.write(buf)
+}
+
+// suggestion returns what Autosuggest would add to the line, suggestions
+// are only made with the cursor at the end of the line. It is remembered
+// until the line changes so Complete runs once per edit, not every redraw.
+func (le *LineEditor) suggestion() string {
+	if !le.Autosuggest || len(le.line) == 0 || le.pos != len(le.line) {
+		return ""
+	}
+	line := string(le.line)
+	if line != le.suggestedFor || le.pos != le.suggestedPos {
+		le.suggested = le.findSuggestion(line)
+		le.suggestedFor = line
+		le.suggestedPos = le.pos
+	}
+	return le.suggested
+}
+
+// findSuggestion looks through the history and then the completions
+func (le *LineEditor) findSuggestion(line string) string {
+	for i := len(le.History) - 1; i >= 0; i-- {
+		if entry := le.History[i]; len(entry) > len(line) && strings.HasPrefix(entry, line) {
+			return entry[len(line):]
+		}
+	}
+
+	word := line[strings.LastIndexByte(line, ' ')+1:]
+	if le.Complete == nil || len(word) == 0 {
+		return ""
+	}
+	if choices := le.Complete(line); len(choices) == 1 && len(choices[0]) > len(word) && strings.HasPrefix(choices[0], word) {
+		return choices[0][len(word):]
+	}
+	return ""
+}
+
+// acceptSuggestion adds the suggestion, or just its next word, to the line.
+// Without one the cursor moves right a character or a word instead.
+func (le *LineEditor) acceptSuggestion(word bool) {
+	suggestion := []rune(le.suggestion())
+	if len(suggestion) == 0 {
+		if word {
+			le.moveTo(wordEnd(le.line, le.pos))
+		} else {
+			le.moveTo(le.pos + 1)
+		}
+		return
+	}
+	if word {
+		suggestion = suggestion[:wordEnd(suggestion, 0)]
+	}
+	le.insert(suggestion...)
+}
+
+// clearSuggestion erases a suggestion shown after the cursor before the
+// editor moves on to the next line
+func (le *LineEditor) clearSuggestion() {
+	if len(le.suggestion()) > 0 {
+		le.write("\x1b[K")
+	}
+}
+
+// showBelow prints the text under the line and then redraws the line
+func (le *LineEditor) showBelow(text string) {
+	if len(text) > 0 && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	le.clearSuggestion()
+	le.write("\r\n" + crlf(text))
+	le.refreshLocked()
+}
+
+func (le *LineEditor) insert(r ... rune) {
+	line := make([]rune, 0, len(le.line) + len(r))
+	line = append(line, le.line[:le.pos]...)
+	line = append(line, r...)
+	le.line = append(line, le.line[le.pos:]...)
+	le.pos += len(r)
+	le.refreshLocked()
+}
+
+func (le *LineEditor) deleteRange(from int, to int) {
+	if to > len(le.line) {
+		to = len(le.line)
+	}
+	if from < 0 || from >= to {
+		return
+	}
+	le.line = append(le.line[:from], le.line[to:]...)
+	le.pos = from
+	le.refreshLocked()
+}
+
+func (le *LineEditor) moveTo(pos int) {
+	if pos >= 0 && pos <= len(le.line) && pos != le.pos {
+		le.pos = pos
+		le.refreshLocked()
+	}
+}
+
+func (le *LineEditor) setLine(line []rune) {
+	le.line = line
+	le.pos = len(line)
+	le.refreshLocked()
+}
+
+// historyMove moves through the history by delta entries, as far as it goes
+func (le *LineEditor) historyMove(delta int) {
+	index := max(min(le.histIndex + delta, len(le.History)), 0)
+	if index == le.histIndex {
+		return
+	}
+	if le.histIndex == len(le.History) {
+		le.pending = le.line
+	}
+	le.histIndex = index
+	if index == len(le.History) {
+		le.setLine(le.pending)
+	} else {
+		le.setLine([]rune(le.History[index]))
+	}
+}
+
+// complete replaces the word before the cursor with the completion, or the
+// common prefix of all of them. Listing the choices takes a second tab.
+func (le *LineEditor) complete() bool {
+	if le.Complete == nil {
+		return false
+	}
+	start := le.pos
+	for start > 0 && le.line[start-1] != ' ' {
+		start--
+	}
+	word := string(le.line[start:le.pos])
+	choices := le.Complete(string(le.line[:le.pos]))
+	if len(choices) == 0 {
+		return false
+	}
+
+	replacement := choices[0]
+	if len(choices) == 1 {
+		replacement += " "
+	} else {
+		replacement = commonPrefix(choices)
+	}
+
+	if replacement != word {
+		rest := le.line[le.pos:]
+		line := append(append([]rune{}, le.line[:start]...), []rune(replacement)...)
+		le.pos = len(line)
+		le.line = append(line, rest...)
+		le.refreshLocked()
+		return false
+	} else if le.lastTab {
+		le.showBelow(strings.Join(choices, "  "))
+	}
+	return true
+}
+
+func commonPrefix(choices []string) string {
+	prefix := []rune(choices[0])
+	for _, c := range choices[1:] {
+		runes := []rune(c)
+		i := 0
+		for i < len(prefix) && i < len(runes) && prefix[i] == runes[i] {
+			i++
+		}
+		prefix = prefix[:i]
+	}
+	return string(prefix)
+}
+
+// wordStart finds the start of the word before pos
+func wordStart(line []rune, pos int) int {
+	i := pos
+	for i > 0 && line[i-1] == ' ' {
+		i--
+	}
+	for i > 0 && line[i-1] != ' ' {
+		i--
+	}
+	return i
+}
+
+// wordEnd finds the end of the word after pos
+func wordEnd(line []rune, pos int) int {
+	i := pos
+	for i < len(line) && line[i] == ' ' {
+		i++
+	}
+	for i < len(line) && line[i] != ' ' {
+		i++
+	}
+	return i
+}
+
+func inQuotes(line []rune) bool {
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0 && r == '\\':
+			escaped = true
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case r == quote:
+			quote = 0
+		}
+	}
+	return quote != 0
+}
@@ -0,0 +1,204 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package terminal_test
+
+import (
+	"bytes"
+	"github.com/threeguys/golang-ezshell/terminal"
+	"github.com/threeguys/golang-toolkit/objects"
+	"io"
+	"strings"
+	"testing"
+)
+
+func readLines(t *testing.T, le *terminal.LineEditor, count int) []string {
+	assert := objects.NewTestAssertions(t)
+	lines := make([]string, 0)
+	for i := 0; i < count; i++ {
+		line, err := le.ReadLine()
+		assert.Nil(err)
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestLineEditor_ReadLine(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	out := new(bytes.Buffer)
+	le := terminal.NewLineEditor(strings.NewReader("hello\r"), out)
+	le.Prompt = "> "
+
+	assert.Equal([]string{ "hello" }, readLines(t, le, 1))
+	assert.True(strings.HasPrefix(out.String(), "\r> \x1b[K"))
+	assert.True(strings.HasSuffix(out.String(), "\r> hello\x1b[K\r\n"))
+	assert.Equal([]string{ "hello" }, le.History)
+
+	_, err := le.ReadLine()
+	assert.Equal(io.EOF, err)
+}
+
+func TestLineEditor_Editing(t *testing.T) {
+	input := strings.Join([]string{
+		"wrld\x1b[D\x1b[D\x1b[Do\x01hello \r",     // arrows and ctrl-a
+		"abc def\x17xyz\r",                         // ctrl-w
+		"abcdef\x02\x02\x0b\r",                     // ctrl-b and ctrl-k
+		"abcdef\x02\x02\x15\r",                     // ctrl-u
+		"abc\x7f\x7fz\x1b[H\x1b[3~\r",              // backspace, home and delete
+		"kept\r",
+		"xy\x01\x04\r",                              // ctrl-d deletes when not empty
+		"last",                                     // final line without enter
+	}, "")
+
+	le := terminal.NewLineEditor(strings.NewReader(input), new(bytes.Buffer))
+	lines := readLines(t, le, 8)
+	assert := objects.NewTestAssertions(t)
+	assert.Equal([]string{ "hello world", "abc xyz", "abcd", "ef", "z", "kept", "y", "last" }, lines)
+}
+
+func TestLineEditor_History(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	input := "one\rtwo\rtwo\r\x1b[A\x1b[A\x1b[A\r" + "new\x1b[A\x1b[B!\r" + "\x10\x10\x0e\r"
+	le := terminal.NewLineEditor(strings.NewReader(input), new(bytes.Buffer))
+
+	lines := readLines(t, le, 6)
+	assert.Equal([]string{ "one", "two", "two", "one", "new!", "new!" }, lines)
+	assert.Equal([]string{ "one", "two", "one", "new!" }, le.History)
+}
+
+func TestLineEditor_CtrlC(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	out := new(bytes.Buffer)
+	le := terminal.NewLineEditor(strings.NewReader("gone\x03kept\r"), out)
+
+	_, err := le.ReadLine()
+	assert.Equal(terminal.ErrInterrupted, err)
+	assert.True(strings.HasSuffix(out.String(), "gone\x1b[K^C\r\n"))
+	assert.Equal([]string{ "kept" }, readLines(t, le, 1))
+	assert.Equal([]string{ "kept" }, le.History)
+}
+
+func TestLineEditor_CtrlD(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	out := new(bytes.Buffer)
+	le := terminal.NewLineEditor(strings.NewReader("\x04more\r"), out)
+	le.Prompt = "$ "
+
+	_, err := le.ReadLine()
+	assert.Equal(io.EOF, err)
+	assert.True(strings.HasSuffix(out.String(), "\r\n"))
+	assert.Equal([]string{ "more" }, readLines(t, le, 1))
+}
+
+func TestLineEditor_Help(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	out := new(bytes.Buffer)
+	le := terminal.NewLineEditor(strings.NewReader("show ?x\r" + "echo \"what?\"\r"), out)
+	le.Prompt = "# "
+	helped := make([]string, 0)
+	le.Help = func(line string) string {
+		helped = append(helped, line)
+		return "  version\n  config"
+	}
+
+	assert.Equal([]string{ "show x", "echo \"what?\"" }, readLines(t, le, 2))
+	assert.Equal([]string{ "show " }, helped)
+	assert.True(strings.Contains(out.String(), "\r\n  version\r\n  config\r\n\r# show \x1b[K"))
+}
+
+func TestLineEditor_Complete(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	out := new(bytes.Buffer)
+	le := terminal.NewLineEditor(strings.NewReader("sh\tv\t\r" + "sh\tx\t\t\r" + "show \t\r"), out)
+	le.Complete = func(line string) []string {
+		if strings.HasPrefix(line, "show v") {
+			return []string{ "version" }
+		} else if strings.HasPrefix(line, "show x") {
+			return []string{ "xa", "xb" }
+		} else if line == "show " {
+			return []string{ "a", "b" }
+		} else if line == "sh" {
+			return []string{ "show" }
+		}
+		return nil
+	}
+
+	assert.Equal([]string{ "show version ", "show x", "show " }, readLines(t, le, 3))
+	assert.True(strings.Contains(out.String(), "\r\nxa  xb\r\n"))
+}
+
+func TestLineEditor_PrintAbove(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	out := new(bytes.Buffer)
+	le := terminal.NewLineEditor(strings.NewReader(""), out)
+
+	le.PrintAbove("not reading\n")
+	assert.Equal("not reading\n", out.String())
+	assert.Equal("", le.Buffer())
+}
+
+func TestLineEditor_Highlight(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	out := new(bytes.Buffer)
+	le := terminal.NewLineEditor(strings.NewReader("ab\x1b[D\r"), out)
+	le.Highlight = func(line string) string {
+		return "\x1b[1m" + line + "\x1b[0m"
+	}
+
+	assert.Equal([]string{ "ab" }, readLines(t, le, 1))
+	assert.True(strings.Contains(out.String(), "\r\x1b[1mab\x1b[0m\x1b[K\x1b[1D"))
+}
+
+func TestLineEditor_Autosuggest(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	out := new(bytes.Buffer)
+	input := "show version\r" + "show config all\r" +
+		"show v\x1b[C\r" +     // right arrow takes the newest match
+		"sh\x06\r" +           // ctrl-f
+		"sh\x1bf\r" +          // alt-f a word at a time
+		"conf\x06\r" +         // falls back to completion
+		"xyz\x01\x1bf!\r"      // without a suggestion alt-f moves a word
+	le := terminal.NewLineEditor(strings.NewReader(input), out)
+	le.Autosuggest = true
+	le.Complete = func(line string) []string {
+		if line == "conf" {
+			return []string{ "configure" }
+		}
+		return nil
+	}
+
+	lines := readLines(t, le, 7)
+	assert.Equal([]string{ "show version", "show config all", "show version", "show version", "show",
+		"configure", "xyz!" }, lines)
+	assert.True(strings.Contains(out.String(), "\rshow v\x1b[K\x1b[2mersion\x1b[0m\x1b[6D"))
+	assert.True(strings.Contains(out.String(), "\rsh\x1b[K\x1b[2mow config all\x1b[0m\x1b[13D"))
+	assert.True(strings.Contains(out.String(), "\rshow\x1b[K\x1b[2m version\x1b[0m\x1b[8D\x1b[K\r\n"))
+}
+
+func TestLineEditor_Autosuggest_CompletesOncePerEdit(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	calls := make([]string, 0)
+	le := terminal.NewLineEditor(strings.NewReader("xyz\x0c\x0c\x02\x06\rxyz\r"), new(bytes.Buffer))
+	le.Autosuggest = true
+	le.Complete = func(line string) []string {
+		calls = append(calls, line)
+		return nil
+	}
+
+	// Redraws and moving back to the same place don't complete again, and
+	// the second line only completes once history has nothing longer
+	assert.Equal([]string{ "xyz", "xyz" }, readLines(t, le, 2))
+	assert.Equal([]string{ "x", "xy", "xyz", "xyz" }, calls)
+}
@@ -0,0 +1,403 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package terminal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Pager shows text a screen at a time. Space and b move a page forwards and
+// back, enter and k a line, g and G to the top and bottom, / searches for a
+// regular expression with n and N finding the next and previous match, and
+// q quits.
+//
+// Text written to it goes straight through until it would fill the screen,
+// then paging starts. Paging waits for more to be written when it gets to
+// the end before Close, and anything written after q is thrown away.
+type Pager struct {
+	in *bufio.Reader
+	out io.Writer
+	file *os.File
+
+	Width int
+	Height int
+
+	lines []string
+	top int
+	want int
+	search *regexp.Regexp
+	message string
+
+	partial string
+	written int
+	shown int
+	paging bool
+	quit bool
+	done bool
+	err error
+}
+
+// NewPager creates a pager for the reader and writer, the caller is
+// responsible for any terminal settings
+func NewPager(in io.Reader, out io.Writer) *Pager {
+	width, height := DefaultSize()
+	return &Pager{
+		in:     bufio.NewReader(in),
+		out:    out,
+		Width:  width,
+		Height: height,
+	}
+}
+
+// NewTerminalPager creates a pager the size of the terminal, which is
+// switched to raw mode while paging
+func NewTerminalPager(in *os.File, out io.Writer) *Pager {
+	p := NewPager(in, out)
+	p.file = in
+	if fd, ok := Fd(out); ok {
+		p.Width, p.Height = SizeOrDefault(fd)
+	} else {
+		p.Width, p.Height = SizeOrDefault(in.Fd())
+	}
+	return p
+}
+
+// Fits reports whether the text can be shown without paging
+func (p *Pager) Fits(text string) bool {
+	return len(splitLines(text)) < p.Height
+}
+
+// Page shows the text until the user quits, text which fits on the screen
+// is written out as it is
+func (p *Pager) Page(text string) error {
+	p.add(text)
+	return p.Close()
+}
+
+// Write adds to the text being shown, the user may page through it before
+// Write returns
+func (p *Pager) Write(data []byte) (int, error) {
+	p.add(string(data))
+	p.resume()
+	return len(data), p.err
+}
+
+// Close marks the end of the text, if it didn't fit on the screen it is
+// paged until the user quits
+func (p *Pager) Close() error {
+	if len(p.partial) > 0 && p.paging {
+		p.lines = append(p.lines, p.partial)
+	}
+	p.partial = ""
+	p.done = true
+	p.resume()
+	return p.err
+}
+
+// add splits the text into lines, writing them straight out until they
+// would fill the screen and keeping them for paging after that
+func (p *Pager) add(text string) {
+	if p.quit {
+		return
+	}
+	p.partial += text
+	for {
+		end := strings.IndexByte(p.partial, '\n')
+		if end < 0 {
+			break
+		}
+		line := p.partial[:end]
+		p.partial = p.partial[end+1:]
+
+		p.lines = append(p.lines, line)
+		if !p.paging {
+			if rows := p.rowsFor(line); p.shown + rows < p.Height {
+				p.write(line[p.written:] + "\n")
+				p.shown += rows
+			} else {
+				p.paging = true
+			}
+		}
+		p.written = 0
+	}
+
+	// Part of a line, like a progress message, is shown straight away
+	if !p.paging && len(p.partial) > p.written {
+		p.write(p.partial[p.written:])
+		p.written = len(p.partial)
+	}
+}
+
+// rowsFor is how many rows the line takes up when it wraps
+func (p *Pager) rowsFor(line string) int {
+	width := visibleWidth(line)
+	if p.Width <= 0 || width <= p.Width {
+		return 1
+	}
+	return (width + p.Width - 1) / p.Width
+}
+
+// resume goes back to paging once the lines the user asked for have been
+// written, or there won't be any more
+func (p *Pager) resume() {
+	if !p.paging || p.quit || (!p.done && p.want > p.bottom()) {
+		return
+	}
+	p.message = ""
+	p.scrollTo(p.want)
+	p.interact()
+}
+
+// interact draws the page and reads keys until the user quits, or moves
+// past the end of what has been written so far
+func (p *Pager) interact() {
+	if p.file != nil {
+		if state, err := MakeRaw(p.file.Fd()); err == nil {
+			defer func() {
+				if err := Restore(p.file.Fd(), state); err != nil {
+					log.Println("Unable to restore terminal", err)
+				}
+			}()
+		}
+	}
+
+	for {
+		if !p.done && p.want > p.bottom() {
+			p.message = "Waiting for more output"
+			p.draw()
+			return
+		}
+		p.draw()
+		k, err := readKey(p.in)
+		if err != nil && err != io.EOF {
+			p.err = err
+		}
+		if err != nil || !p.handleKey(k) {
+			p.quit = true
+			p.write("\r\x1b[K")
+			return
+		}
+	}
+}
+
+func splitLines(text string) []string {
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func (p *Pager) write(out string) {
+	if _, err := io.WriteString(p.out, out); err != nil {
+		log.Println("Unable to write to terminal", err)
+	}
+}
+
+// rows is how many lines of text fit above the status line
+func (p *Pager) rows() int {
+	if p.Height > 1 {
+		return p.Height - 1
+	}
+	return 1
+}
+
+// bottom is the last line which can be at the top of the screen
+func (p *Pager) bottom() int {
+	if bottom := len(p.lines) - p.rows(); bottom > 0 {
+		return bottom
+	}
+	return 0
+}
+
+// scrollTo moves to the line, as far as the lines written so far go
+func (p *Pager) scrollTo(top int) {
+	if top < 0 {
+		top = 0
+	}
+	p.want = top
+	if bottom := p.bottom(); top > bottom {
+		top = bottom
+	}
+	p.top = top
+}
+
+// handleKey acts on the key, returning false when the pager should quit
+func (p *Pager) handleKey(k key) bool {
+	p.message = ""
+	switch k.code {
+	case keyEnter, keyDown:
+		p.scrollTo(p.top + 1)
+	case keyUp:
+		p.scrollTo(p.top - 1)
+	case keyPageDown:
+		p.scrollTo(p.top + p.rows())
+	case keyPageUp:
+		p.scrollTo(p.top - p.rows())
+	case keyHome:
+		p.scrollTo(0)
+	case keyEnd:
+		p.scrollTo(len(p.lines))
+	case keyRune:
+		switch k.r {
+		case 'q', 'Q', ctrlC:
+			return false
+		case ' ', 'f', ctrlF:
+			p.scrollTo(p.top + p.rows())
+		case 'b', ctrlB:
+			p.scrollTo(p.top - p.rows())
+		case 'j', ctrlN:
+			p.scrollTo(p.top + 1)
+		case 'k', ctrlP:
+			p.scrollTo(p.top - 1)
+		case 'g', '<':
+			p.scrollTo(0)
+		case 'G', '>':
+			p.scrollTo(len(p.lines))
+		case '/':
+			p.startSearch()
+		case 'n':
+			p.find(p.top + 1, 1)
+		case 'N':
+			p.find(p.top - 1, -1)
+		}
+	}
+	return true
+}
+
+// startSearch reads a pattern on the status line and jumps to its first
+// match after the top line, escape cancels
+func (p *Pager) startSearch() {
+	pattern := make([]rune, 0)
+	for {
+		p.write("\r\x1b[K/" + string(pattern))
+		k, err := readKey(p.in)
+		if err != nil || k.code == keyEscape {
+			return
+		} else if k.code == keyEnter {
+			break
+		} else if k.code == keyRune && (k.r == backspace || k.r == ctrlH) {
+			if len(pattern) == 0 {
+				return
+			}
+			pattern = pattern[:len(pattern)-1]
+		} else if k.code == keyRune && k.r == ctrlC {
+			return
+		} else if k.code == keyRune && k.r >= ' ' && !k.alt {
+			pattern = append(pattern, k.r)
+		}
+	}
+
+	if len(pattern) > 0 {
+		re, err := regexp.Compile(string(pattern))
+		if err != nil {
+			re = regexp.MustCompile(regexp.QuoteMeta(string(pattern)))
+		}
+		p.search = re
+	}
+	p.find(p.top + 1, 1)
+}
+
+// find scrolls to the next line matching the search starting at from and
+// moving in the direction of step
+func (p *Pager) find(from int, step int) {
+	if p.search == nil {
+		p.message = "No previous search"
+		return
+	}
+	for i := from; i >= 0 && i < len(p.lines); i += step {
+		if p.search.MatchString(p.lines[i]) {
+			p.scrollTo(i)
+			return
+		}
+	}
+	p.message = "Pattern not found"
+}
+
+func (p *Pager) status() string {
+	if len(p.message) > 0 {
+		return p.message
+	}
+	bottom := p.top + p.rows()
+	if bottom >= len(p.lines) && p.done {
+		return "(END)"
+	} else if bottom > len(p.lines) {
+		bottom = len(p.lines)
+	}
+	if !p.done {
+		return fmt.Sprintf("lines %d-%d of %d so far (space, b, /, q)", p.top + 1, bottom, len(p.lines))
+	}
+	return fmt.Sprintf("lines %d-%d of %d (space, b, /, q)", p.top + 1, bottom, len(p.lines))
+}
+
+// draw clears the screen and shows the lines from top, with the status
+// line at the bottom
+func (p *Pager) draw() {
+	sb := &strings.Builder{}
+	sb.WriteString("\x1b[H\x1b[2J")
+	for i := p.top; i < p.top + p.rows() && i < len(p.lines); i++ {
+		sb.WriteString(truncate(p.lines[i], p.Width))
+		sb.WriteString("\r\n")
+	}
+	sb.WriteString("\x1b[7m" + p.status() + "\x1b[0m")
+	p.write(sb.String())
+}
+
+// visibleWidth is how many characters of the line take up space, escape
+// sequences for colors don't
+func visibleWidth(line string) int {
+	width := 0
+	escaped := false
+	for _, r := range strings.TrimSuffix(line, "\r") {
+		switch {
+		case r == escape:
+			escaped = true
+		case escaped:
+			escaped = r < 0x40 || r > 0x7e || r == '['
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+// truncate cuts the line to the width so every line takes up one row,
+// escape sequences for colors don't count towards the width
+func truncate(line string, width int) string {
+	line = strings.TrimSuffix(line, "\r")
+	if width <= 0 {
+		return line
+	}
+	visible := 0
+	escaped, styled := false, false
+	for i, r := range line {
+		switch {
+		case r == escape:
+			escaped, styled = true, true
+		case escaped:
+			escaped = r < 0x40 || r > 0x7e || r == '['
+		case visible == width && styled:
+			return line[:i] + "\x1b[0m"
+		case visible == width:
+			return line[:i]
+		default:
+			visible++
+		}
+	}
+	return line
+}
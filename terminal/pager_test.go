@@ -0,0 +1,127 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package terminal_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/threeguys/golang-ezshell/terminal"
+	"github.com/threeguys/golang-toolkit/objects"
+	"strings"
+	"testing"
+)
+
+func numberedLines(count int) string {
+	sb := &strings.Builder{}
+	for i := 1; i <= count; i++ {
+		fmt.Fprintf(sb, "line %d\n", i)
+	}
+	return sb.String()
+}
+
+// screens splits the pager's output into the screens it drew
+func screens(out string) []string {
+	parts := strings.Split(out, "\x1b[H\x1b[2J")
+	return parts[1:]
+}
+
+func createPager(input string) (*terminal.Pager, *bytes.Buffer) {
+	out := new(bytes.Buffer)
+	p := terminal.NewPager(strings.NewReader(input), out)
+	p.Width = 20
+	p.Height = 4
+	return p, out
+}
+
+func TestPager_Fits(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	p, out := createPager("")
+
+	assert.True(p.Fits(numberedLines(3)))
+	assert.False(p.Fits(numberedLines(4)))
+	assert.Nil(p.Page(numberedLines(3)))
+	assert.Equal(numberedLines(3), out.String())
+}
+
+func TestPager_Paging(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	p, out := createPager(" \rbkG \x1b[6~q")
+
+	assert.Nil(p.Page(numberedLines(10)))
+	drawn := screens(out.String())
+	assert.Equal(8, len(drawn))
+	assert.Equal("line 1\r\nline 2\r\nline 3\r\n\x1b[7mlines 1-3 of 10 (space, b, /, q)\x1b[0m", drawn[0])
+	assert.True(strings.HasPrefix(drawn[1], "line 4\r\n"))  // space
+	assert.True(strings.HasPrefix(drawn[2], "line 5\r\n"))  // enter
+	assert.True(strings.HasPrefix(drawn[3], "line 2\r\n"))  // b
+	assert.True(strings.HasPrefix(drawn[4], "line 1\r\n"))  // k
+	assert.Equal("line 8\r\nline 9\r\nline 10\r\n\x1b[7m(END)\x1b[0m", drawn[5])  // G
+	assert.True(strings.HasPrefix(drawn[6], "line 8\r\n"))  // space stops at the end
+	assert.True(strings.HasPrefix(drawn[7], "line 8\r\n"))  // page down
+	assert.True(strings.HasSuffix(out.String(), "\r\x1b[K"))
+}
+
+func TestPager_Search(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	p, out := createPager("/line [57]\rnNn/nope\r/\x1b")
+
+	assert.Nil(p.Page(numberedLines(10)))
+	drawn := screens(out.String())
+	assert.Equal(7, len(drawn))
+	assert.True(strings.HasPrefix(drawn[1], "line 5\r\n"))
+	assert.True(strings.HasPrefix(drawn[2], "line 7\r\n"))
+	assert.True(strings.HasPrefix(drawn[3], "line 5\r\n"))
+	assert.True(strings.HasPrefix(drawn[4], "line 7\r\n"))
+	assert.True(strings.HasSuffix(drawn[5], "\x1b[7mPattern not found\x1b[0m\r\x1b[K/"))
+	assert.True(strings.HasPrefix(drawn[6], "line 7\r\n"))  // escape cancels the search
+}
+
+func TestPager_Truncate(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	p, out := createPager("q")
+	p.Width = 5
+
+	assert.Nil(p.Page("a very long line\n\x1b[1mb\x1b[0mold and long\nc\nd\n"))
+	assert.True(strings.HasPrefix(screens(out.String())[0], "a ver\r\n\x1b[1mb\x1b[0mold \x1b[0m\r\n"))
+}
+
+func TestPager_Streaming(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	p, out := createPager(" Gq")
+
+	fmt.Fprint(p, "line 1\nline 2\n")
+	assert.Equal("line 1\nline 2\n", out.String())
+	fmt.Fprint(p, "working")
+	assert.Equal("line 1\nline 2\nworking", out.String())
+
+	fmt.Fprint(p, "\nline 3\nline 4\nline 5\n")
+	drawn := screens(out.String())
+	assert.Equal(3, len(drawn))
+	assert.Equal("line 1\r\nline 2\r\nworking\r\n\x1b[7mlines 1-3 of 6 so far (space, b, /, q)\x1b[0m", drawn[0])
+	assert.True(strings.HasPrefix(drawn[1], "line 3\r\n"))  // space
+	assert.True(strings.HasSuffix(drawn[2], "\x1b[7mWaiting for more output\x1b[0m"))  // G
+
+	fmt.Fprint(p, "line 6\n")
+	assert.Equal(3, len(screens(out.String())))
+	assert.Nil(p.Close())
+	drawn = screens(out.String())
+	assert.Equal(4, len(drawn))
+	assert.Equal("line 4\r\nline 5\r\nline 6\r\n\x1b[7m(END)\x1b[0m\r\x1b[K", drawn[3])
+
+	fmt.Fprint(p, "line 7\n")  // after q
+	assert.Equal(4, len(screens(out.String())))
+	assert.True(strings.HasSuffix(out.String(), "\r\x1b[K"))
+}
@@ -0,0 +1,33 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package terminal
+
+// State holds the terminal settings to restore after MakeRaw
+type State struct{}
+
+// MakeRaw puts the terminal into raw mode, returning the previous state
+func MakeRaw(_ uintptr) (*State, error) {
+	return nil, ErrNotSupported
+}
+
+// Restore puts the terminal back the way it was before MakeRaw
+func Restore(_ uintptr, _ *State) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,66 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+
+// Package terminal contains the bits of terminal handling the shell needs,
+// without pulling in any dependencies outside of the standard library.
+package terminal
+
+import (
+	"os"
+	"strconv"
+)
+
+const (
+	DefaultWidth = 80
+	DefaultHeight = 24
+)
+
+// IsTerminal reports whether the file descriptor is attached to a terminal
+func IsTerminal(fd uintptr) bool {
+	_, _, err := Size(fd)
+	return err == nil
+}
+
+// SizeOrDefault returns the size of the terminal, falling back to the
+// COLUMNS and LINES environment variables and then the defaults
+func SizeOrDefault(fd uintptr) (int, int) {
+	if width, height, err := Size(fd); err == nil && width > 0 && height > 0 {
+		return width, height
+	}
+	return DefaultSize()
+}
+
+// DefaultSize is the size to use when there's no terminal, taken from the
+// COLUMNS and LINES environment variables or the defaults
+func DefaultSize() (int, int) {
+	return envOrDefault("COLUMNS", DefaultWidth), envOrDefault("LINES", DefaultHeight)
+}
+
+// Fd returns the file descriptor of a reader or writer backed by a file,
+// such as an *os.File
+func Fd(v interface{}) (uintptr, bool) {
+	if f, ok := v.(interface{ Fd() uintptr }); ok {
+		return f.Fd(), true
+	}
+	return 0, false
+}
+
+func envOrDefault(name string, def int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return def
+}
@@ -0,0 +1,74 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package terminal_test
+
+import (
+	"bytes"
+	"github.com/threeguys/golang-ezshell/terminal"
+	"github.com/threeguys/golang-toolkit/objects"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestIsTerminal_File(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	f, err := ioutil.TempFile(t.TempDir(), "not-a-tty")
+	assert.Nil(err)
+	defer func() { assert.Nil(f.Close()) }()
+
+	assert.False(terminal.IsTerminal(f.Fd()))
+	_, _, err = terminal.Size(f.Fd())
+	assert.True(err != nil)
+}
+
+func TestSizeOrDefault(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	f, err := ioutil.TempFile(t.TempDir(), "not-a-tty")
+	assert.Nil(err)
+	defer func() { assert.Nil(f.Close()) }()
+
+	oldCols, oldLines := os.Getenv("COLUMNS"), os.Getenv("LINES")
+	defer func() {
+		assert.Nil(os.Setenv("COLUMNS", oldCols))
+		assert.Nil(os.Setenv("LINES", oldLines))
+	}()
+
+	assert.Nil(os.Setenv("COLUMNS", ""))
+	assert.Nil(os.Setenv("LINES", "junk"))
+	width, height := terminal.SizeOrDefault(f.Fd())
+	assert.Equal(terminal.DefaultWidth, width)
+	assert.Equal(terminal.DefaultHeight, height)
+
+	assert.Nil(os.Setenv("COLUMNS", "132"))
+	assert.Nil(os.Setenv("LINES", "50"))
+	width, height = terminal.SizeOrDefault(f.Fd())
+	assert.Equal(132, width)
+	assert.Equal(50, height)
+	width, height = terminal.DefaultSize()
+	assert.Equal(132, width)
+	assert.Equal(50, height)
+}
+
+func TestFd(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	fd, ok := terminal.Fd(os.Stdin)
+	assert.True(ok)
+	assert.Equal(os.Stdin.Fd(), fd)
+
+	_, ok = terminal.Fd(new(bytes.Buffer))
+	assert.False(ok)
+}
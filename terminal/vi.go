@@ -0,0 +1,697 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package terminal
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+)
+
+// Keymap selects the keys a LineEditor uses for editing
+type Keymap int
+
+const (
+	EmacsKeymap Keymap = iota
+	ViKeymap
+)
+
+// viState is the vi keymap's state between keys. Lines start in insert
+// mode, escape switches to normal mode.
+type viState struct {
+	normal bool
+	count int
+	op rune
+	opCount int
+	pending rune // f, t, F, T or r waiting for their character
+
+	findCmd rune
+	findChar rune
+	yanked []rune
+	undo []viSnapshot
+
+	// keys holds the keys of the command being typed, they become
+	// lastChange if it changes the line so that '.' can replay them
+	recording bool
+	replaying bool
+	keys []key
+	lastChange []key
+
+	// edit is set by v, ReadLine opens the editor once it has let go of the lock
+	edit bool
+}
+
+type viSnapshot struct {
+	line []rune
+	pos int
+}
+
+// reset gets ready for a new line, the yanked text and the last change
+// are kept for the next one
+func (vi *viState) reset() {
+	vi.normal = false
+	vi.clearCommand()
+	vi.undo = nil
+	vi.recording = false
+	vi.edit = false
+}
+
+func (vi *viState) clearCommand() {
+	vi.count, vi.op, vi.opCount, vi.pending = 0, 0, 0, 0
+}
+
+// handleViKey is handleKey for the vi keymap
+func (le *LineEditor) handleViKey(k key) (string, bool, error) {
+	vi := &le.vi
+	if vi.recording && !vi.replaying {
+		vi.keys = append(vi.keys, k)
+	}
+
+	if !vi.normal {
+		// An escape followed quickly by another key arrives as alt and that key
+		if k.code == keyEscape || (k.code == keyRune && k.alt) {
+			if vi.recording && !vi.replaying {
+				vi.keys[len(vi.keys)-1] = key{ code: keyEscape }
+			}
+			le.viNormalMode()
+			if k.alt {
+				return le.handleViKey(key{ code: keyRune, r: k.r })
+			}
+			return "", false, nil
+		}
+		return le.handleKey(k)
+	}
+
+	if vi.op == 0 && vi.pending == 0 && vi.count == 0 && !vi.replaying {
+		vi.recording = true
+		vi.keys = []key{ k }
+	}
+	return le.viCommand(k)
+}
+
+// viNormalMode leaves insert mode, finishing the change which started it
+func (le *LineEditor) viNormalMode() {
+	vi := &le.vi
+	vi.normal = true
+	if vi.recording && !vi.replaying {
+		vi.lastChange = vi.keys
+	}
+	vi.recording = false
+	vi.clearCommand()
+	le.moveTo(le.pos - 1)
+}
+
+// viInsertMode switches to insert mode at pos, the command which did it
+// keeps recording until escape
+func (le *LineEditor) viInsertMode(pos int) {
+	le.vi.normal = false
+	le.vi.clearCommand()
+	le.pos = pos
+	le.refreshLocked()
+}
+
+// viDone finishes a normal mode command, changed says whether it can be
+// repeated with '.'
+func (le *LineEditor) viDone(changed bool) {
+	vi := &le.vi
+	if changed && vi.recording && !vi.replaying {
+		vi.lastChange = vi.keys
+	}
+	vi.recording = false
+	vi.clearCommand()
+	le.clampCursor()
+}
+
+// clampCursor keeps the cursor on a character, normal mode can't go past
+// the end of the line the way insert mode can
+func (le *LineEditor) clampCursor() {
+	if le.vi.normal && le.pos >= len(le.line) && len(le.line) > 0 {
+		le.moveTo(len(le.line) - 1)
+	}
+}
+
+func (le *LineEditor) viSave() {
+	line := append([]rune{}, le.line...)
+	le.vi.undo = append(le.vi.undo, viSnapshot{ line: line, pos: le.pos })
+}
+
+func (le *LineEditor) viCount() int {
+	count := le.vi.count
+	if count == 0 {
+		count = 1
+	}
+	if le.vi.opCount > 0 {
+		count *= le.vi.opCount
+	}
+	return count
+}
+
+// viKeyRune turns the keys which aren't runes into their vi equivalents
+func viKeyRune(k key) rune {
+	switch k.code {
+	case keyEnter:
+		return '\r'
+	case keyLeft:
+		return 'h'
+	case keyRight:
+		return 'l'
+	case keyUp:
+		return 'k'
+	case keyDown:
+		return 'j'
+	case keyHome:
+		return '0'
+	case keyEnd:
+		return '$'
+	case keyDelete:
+		return 'x'
+	case keyRune:
+		if !k.alt {
+			return k.r
+		}
+	}
+	return 0
+}
+
+// viCommand handles a key in normal mode
+func (le *LineEditor) viCommand(k key) (string, bool, error) {
+	vi := &le.vi
+	r := viKeyRune(k)
+
+	if vi.pending != 0 {
+		cmd := vi.pending
+		vi.pending = 0
+		if cmd == 'r' {
+			le.viReplace(r)
+			return "", false, nil
+		}
+		vi.findCmd, vi.findChar = cmd, r
+		le.viMove(cmd, r)
+		return "", false, nil
+	}
+
+	if (r >= '1' && r <= '9') || (r == '0' && vi.count > 0) {
+		vi.count = vi.count * 10 + int(r - '0')
+		return "", false, nil
+	}
+
+	switch r {
+	case 'f', 't', 'F', 'T', 'r':
+		vi.pending = r
+		return "", false, nil
+	case 'h', 'l', 'w', 'b', 'e', 'W', 'B', 'E', '0', '^', '$', ' ', ';', ',':
+		le.viMove(r, 0)
+		return "", false, nil
+	case 'd', 'c', 'y':
+		if vi.op == r {
+			le.viOperate(r, 0, len(le.line))
+		} else if vi.op != 0 {
+			le.viDone(false)
+		} else {
+			vi.op, vi.opCount, vi.count = r, vi.count, 0
+		}
+		return "", false, nil
+	}
+
+	if vi.op != 0 {
+		le.viDone(false)
+		return "", false, nil
+	}
+
+	count := le.viCount()
+	switch r {
+	case '\r', '\n':
+		return le.handleKey(key{ code: keyEnter })
+	case ctrlC, ctrlD, ctrlL:
+		vi.clearCommand()
+		vi.recording = false
+		if r == ctrlC {
+			vi.normal = false
+		}
+		return le.handleKey(k)
+	case 'i':
+		le.viSave()
+		le.viInsertMode(le.pos)
+	case 'a':
+		le.viSave()
+		le.viInsertMode(min(le.pos + 1, len(le.line)))
+	case 'I':
+		le.viSave()
+		le.viInsertMode(firstNonBlank(le.line))
+	case 'A':
+		le.viSave()
+		le.viInsertMode(len(le.line))
+	case 'x':
+		le.viOperate('d', le.pos, min(le.pos + count, len(le.line)))
+	case 'X':
+		le.viOperate('d', max(le.pos - count, 0), le.pos)
+	case 's':
+		le.viOperate('c', le.pos, min(le.pos + count, len(le.line)))
+	case 'S':
+		le.viOperate('c', 0, len(le.line))
+	case 'D':
+		le.viOperate('d', le.pos, len(le.line))
+	case 'C':
+		le.viOperate('c', le.pos, len(le.line))
+	case 'Y':
+		le.viOperate('y', 0, len(le.line))
+	case 'p', 'P':
+		le.viPut(r == 'p', count)
+	case 'u':
+		le.viUndo()
+	case '.':
+		le.viRepeat()
+	case 'k', '-':
+		le.historyMove(-count)
+		le.viDone(false)
+	case 'j', '+':
+		le.historyMove(count)
+		le.viDone(false)
+	case 'v':
+		vi.clearCommand()
+		vi.recording = false
+		return le.viEdit()
+	default:
+		le.viDone(false)
+	}
+	return "", false, nil
+}
+
+// viMove moves the cursor, or applies the pending operator to the text
+// between the cursor and where the motion would have moved it
+func (le *LineEditor) viMove(motion rune, arg rune) {
+	vi := &le.vi
+	count := le.viCount()
+
+	// cw changes to the end of the word like ce, it doesn't eat the space after
+	if vi.op == 'c' && (motion == 'w' || motion == 'W') && le.pos < len(le.line) && le.line[le.pos] != ' ' {
+		motion += 'e' - 'w'
+	}
+
+	target, inclusive, ok := le.viMotion(motion, arg, count)
+	if !ok {
+		le.viDone(false)
+		return
+	}
+	if vi.op == 0 {
+		vi.clearCommand()
+		vi.recording = false
+		le.moveTo(target)
+		le.clampCursor()
+		return
+	}
+
+	from, to := le.pos, target
+	if to < from {
+		from, to = to, from
+	}
+	if inclusive {
+		to++
+	}
+	le.viOperate(vi.op, from, min(to, len(le.line)))
+}
+
+// viMotion returns where the motion goes from the cursor and whether the
+// character it lands on is included when an operator uses it
+func (le *LineEditor) viMotion(motion rune, arg rune, count int) (int, bool, bool) {
+	line, pos := le.line, le.pos
+	switch motion {
+	case 'h':
+		return max(pos - count, 0), false, pos > 0
+	case 'l', ' ':
+		return min(pos + count, len(line)), false, pos < len(line)
+	case '0':
+		return 0, false, true
+	case '^':
+		return firstNonBlank(line), false, true
+	case '$':
+		return max(len(line) - 1, 0), true, true
+	case 'w', 'W':
+		for i := 0; i < count; i++ {
+			pos = nextWordStart(line, pos, motion == 'W')
+		}
+		return pos, false, true
+	case 'b', 'B':
+		for i := 0; i < count; i++ {
+			pos = prevWordStart(line, pos, motion == 'B')
+		}
+		return pos, false, true
+	case 'e', 'E':
+		for i := 0; i < count; i++ {
+			pos = nextWordEnd(line, pos, motion == 'E')
+		}
+		return pos, true, true
+	case ';', ',':
+		if le.vi.findCmd == 0 {
+			return pos, false, false
+		}
+		cmd := le.vi.findCmd
+		if motion == ',' {
+			cmd = reverseFind(cmd)
+		}
+		return le.viFind(cmd, le.vi.findChar, count)
+	case 'f', 't', 'F', 'T':
+		return le.viFind(motion, arg, count)
+	}
+	return pos, false, false
+}
+
+// viFind looks for the count'th char on the line, f and t look forwards
+// and stop on or just before it, F and T look backwards
+func (le *LineEditor) viFind(cmd rune, char rune, count int) (int, bool, bool) {
+	line, pos := le.line, le.pos
+	step := 1
+	if cmd == 'F' || cmd == 'T' {
+		step = -1
+	}
+	start := pos + step
+	if (cmd == 't' || cmd == 'T') && start >= 0 && start < len(line) && line[start] == char {
+		// Repeating t shouldn't get stuck just before the same character
+		start += step
+	}
+	for i := start; i >= 0 && i < len(line); i += step {
+		if line[i] == char {
+			if count--; count == 0 {
+				switch cmd {
+				case 't':
+					i--
+				case 'T':
+					i++
+				}
+				return i, step > 0, true
+			}
+		}
+	}
+	return pos, false, false
+}
+
+func reverseFind(cmd rune) rune {
+	switch cmd {
+	case 'f':
+		return 'F'
+	case 'F':
+		return 'f'
+	case 't':
+		return 'T'
+	default:
+		return 't'
+	}
+}
+
+// viOperate deletes, changes or yanks the text from from up to to
+func (le *LineEditor) viOperate(op rune, from int, to int) {
+	if from > to || to > len(le.line) || (from == to && op != 'c') {
+		le.viDone(false)
+		return
+	}
+	le.vi.yanked = append([]rune{}, le.line[from:to]...)
+	switch op {
+	case 'y':
+		le.moveTo(from)
+		le.viDone(false)
+	case 'd':
+		le.viSave()
+		le.line = append(le.line[:from], le.line[to:]...)
+		le.pos = from
+		le.refreshLocked()
+		le.viDone(true)
+	case 'c':
+		le.viSave()
+		le.line = append(le.line[:from], le.line[to:]...)
+		le.viInsertMode(from)
+	}
+}
+
+// viPut inserts the yanked text after or before the cursor
+func (le *LineEditor) viPut(after bool, count int) {
+	if len(le.vi.yanked) == 0 {
+		le.viDone(false)
+		return
+	}
+	le.viSave()
+	pos := le.pos
+	if after && len(le.line) > 0 {
+		pos++
+	}
+	text := make([]rune, 0)
+	for i := 0; i < count; i++ {
+		text = append(text, le.vi.yanked...)
+	}
+	le.pos = pos
+	le.insert(text...)
+	le.moveTo(le.pos - 1)
+	le.viDone(true)
+}
+
+func (le *LineEditor) viReplace(r rune) {
+	count := le.viCount()
+	if r < ' ' || le.pos + count > len(le.line) {
+		le.viDone(false)
+		return
+	}
+	le.viSave()
+	for i := 0; i < count; i++ {
+		le.line[le.pos + i] = r
+	}
+	le.pos += count - 1
+	le.refreshLocked()
+	le.viDone(true)
+}
+
+func (le *LineEditor) viUndo() {
+	vi := &le.vi
+	if len(vi.undo) > 0 {
+		last := vi.undo[len(vi.undo)-1]
+		vi.undo = vi.undo[:len(vi.undo)-1]
+		le.line, le.pos = last.line, last.pos
+		le.refreshLocked()
+	}
+	le.viDone(false)
+}
+
+// viRepeat replays the keys of the last change, a count typed before '.'
+// takes the place of the one the change was made with
+func (le *LineEditor) viRepeat() {
+	vi := &le.vi
+	keys := vi.lastChange
+	count := vi.count
+	vi.clearCommand()
+	if count > 0 {
+		keys = withoutCount(keys)
+		vi.count = count
+	}
+	vi.recording = false
+	vi.replaying = true
+	defer func() { vi.replaying = false }()
+	for _, k := range keys {
+		le.handleViKey(k)
+	}
+	if !vi.normal {
+		le.viNormalMode()
+	}
+}
+
+// withoutCount drops the counts from the start of a change and after its
+// operator, so "3dw" and "d3w" both become "dw"
+func withoutCount(keys []key) []key {
+	skipCount := func(keys []key) []key {
+		if len(keys) == 0 || keys[0].code != keyRune || keys[0].r < '1' || keys[0].r > '9' {
+			return keys
+		}
+		for len(keys) > 0 && keys[0].code == keyRune && keys[0].r >= '0' && keys[0].r <= '9' {
+			keys = keys[1:]
+		}
+		return keys
+	}
+
+	keys = skipCount(keys)
+	if len(keys) > 0 && keys[0].code == keyRune && strings.ContainsRune("dcy", keys[0].r) {
+		return append([]key{ keys[0] }, skipCount(keys[1:])...)
+	}
+	return keys
+}
+
+// viEdit asks ReadLine to open the line in $VISUAL or $EDITOR, which it
+// does without holding the lock so output isn't held up while it's open
+func (le *LineEditor) viEdit() (string, bool, error) {
+	le.vi.edit = true
+	return "", false, nil
+}
+
+// runEditor opens the line in $VISUAL or $EDITOR, what was saved is read a
+// line at a time as if it had been typed at the prompt. Output printed while
+// the editor is open is written straight out.
+func (le *LineEditor) runEditor() (string, bool, error) {
+	le.lock.Lock()
+	line := string(le.line)
+	le.reading = false
+	le.write("\r\n")
+	le.lock.Unlock()
+
+	edited, err := le.editExternally(line)
+
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	le.reading = true
+	if err != nil {
+		le.showBelow("editor: " + err.Error())
+		return "", false, nil
+	}
+
+	lines := strings.Split(strings.TrimSuffix(edited, "\n"), "\n")
+	le.line = []rune(lines[0])
+	le.pos = len(le.line)
+	le.queued = lines[1:]
+	le.refreshLocked()
+	return le.handleKey(key{ code: keyEnter })
+}
+
+// editorOutput writes the external editor's output under the lock, so it
+// doesn't get mixed up with lines printed while the editor is open
+type editorOutput struct {
+	le *LineEditor
+}
+
+func (eo editorOutput) Write(data []byte) (int, error) {
+	eo.le.lock.Lock()
+	defer eo.le.lock.Unlock()
+	return eo.le.out.Write(data)
+}
+
+func (le *LineEditor) editExternally(line string) (string, error) {
+	f, err := ioutil.TempFile("", "ezshell-*.sh")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := io.WriteString(f, line + "\n"); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	editor := strings.Fields(os.Getenv("VISUAL"))
+	if len(editor) == 0 {
+		editor = strings.Fields(os.Getenv("EDITOR"))
+	}
+	if len(editor) == 0 {
+		editor = []string{ "vi" }
+	}
+
+	cmd := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	cmd.Stdout = editorOutput{ le }
+	cmd.Stderr = editorOutput{ le }
+	if le.file != nil {
+		cmd.Stdin = le.file
+		if le.raw != nil {
+			if err := Restore(le.file.Fd(), le.raw); err == nil {
+				defer func() { _, _ = MakeRaw(le.file.Fd()) }()
+			}
+		}
+	}
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadFile(f.Name())
+	return string(data), err
+}
+
+// wordClass splits runes into blanks, word characters and punctuation,
+// with bigWords everything which isn't blank is part of the word
+func wordClass(r rune, bigWords bool) int {
+	switch {
+	case r == ' ' || r == '\t':
+		return 0
+	case bigWords || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func nextWordStart(line []rune, pos int, bigWords bool) int {
+	if pos >= len(line) {
+		return len(line)
+	}
+	class := wordClass(line[pos], bigWords)
+	i := pos
+	for i < len(line) && class != 0 && wordClass(line[i], bigWords) == class {
+		i++
+	}
+	for i < len(line) && wordClass(line[i], bigWords) == 0 {
+		i++
+	}
+	return i
+}
+
+func prevWordStart(line []rune, pos int, bigWords bool) int {
+	i := pos
+	for i > 0 && wordClass(line[i-1], bigWords) == 0 {
+		i--
+	}
+	if i == 0 {
+		return 0
+	}
+	class := wordClass(line[i-1], bigWords)
+	for i > 0 && wordClass(line[i-1], bigWords) == class {
+		i--
+	}
+	return i
+}
+
+func nextWordEnd(line []rune, pos int, bigWords bool) int {
+	i := pos + 1
+	for i < len(line) && wordClass(line[i], bigWords) == 0 {
+		i++
+	}
+	if i >= len(line) {
+		return max(len(line) - 1, 0)
+	}
+	class := wordClass(line[i], bigWords)
+	for i + 1 < len(line) && wordClass(line[i+1], bigWords) == class {
+		i++
+	}
+	return i
+}
+
+func firstNonBlank(line []rune) int {
+	for i, r := range line {
+		if r != ' ' && r != '\t' {
+			return i
+		}
+	}
+	return len(line)
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
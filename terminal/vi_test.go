@@ -0,0 +1,136 @@
+//
+// Copyright 2021 Three Guys Labs, LLC
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+package terminal_test
+
+import (
+	"bytes"
+	"github.com/threeguys/golang-ezshell/terminal"
+	"github.com/threeguys/golang-toolkit/objects"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createViEditor(input string) *terminal.LineEditor {
+	le := terminal.NewLineEditor(strings.NewReader(input), new(bytes.Buffer))
+	le.Keymap = terminal.ViKeymap
+	return le
+}
+
+// An escape followed by another key in the same read is taken as escape
+// and then that key in normal mode, which is what these tests rely on
+func TestLineEditor_Vi(t *testing.T) {
+	input := strings.Join([]string{
+		"hello world\x1bbdw\r",           // b and dw
+		"one two three\x1b0wcwTWO\x1b\r", // cw
+		"a b c d\x1b0x..\r",              // . repeats x
+		"abcdefgh\x1b02x3.\r",            // a count before . replaces the change's
+		"a b c d e\x1b0d2w.\r",           // . repeats d2w
+		"a b c d e\x1b0d2w1.\r",          // 1. replaces the count after the operator
+		"a-b-c-d\x1b0f-;D\r",             // f, ; and D
+		"foo bar baz\x1b02dwx\r",         // counts
+		"ab\x1b0ylp\r",                   // yank and put
+		"abc\x1b0xxu\r",                  // undo
+		"mid\x1bIstart \x1bAend\x1b0\r",  // I and A
+		"aa bb cc\x1b0cwX\x1bw.\r",       // . repeats a change with an insert
+		"cat\x1b0rb\r",                   // r
+		"alpha beta\x1b0de\r",            // de
+		"x.y z\x1b0dW\r",                 // dW
+		"a(b)c\x1b0dt)\r",                // dt
+		"\x1bk\r",                        // k goes back in history
+	}, "")
+
+	assert := objects.NewTestAssertions(t)
+	lines := readLines(t, createViEditor(input), 17)
+	assert.Equal([]string{ "hello ", "one TWO three", " c d", "fgh", "e", "d e", "a-b", "az", "aab", "bc", "start midend",
+		"X X cc", "bat", " beta", "z", ")c", ")c" }, lines)
+}
+
+func TestLineEditor_ViModes(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+
+	// Every line starts in insert mode, and typing in it works like emacs mode
+	le := createViEditor("ab\x1bhix\r" + "cd\x01e\r")
+	assert.Equal([]string{ "xab", "ecd" }, readLines(t, le, 2))
+
+	// A count past the end of the history goes as far as it can
+	le = createViEditor("one\r" + "two\r" + "\x1b50k\r" + "x\x1bk50j\r")
+	assert.Equal([]string{ "one", "two", "one", "x" }, readLines(t, le, 4))
+
+	// Motions which can't move cancel a pending operator
+	le = createViEditor("abc\x1b0dhx\r")
+	assert.Equal([]string{ "bc" }, readLines(t, le, 1))
+}
+
+func TestLineEditor_ViEditor(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "editor.sh")
+	assert.Nil(ioutil.WriteFile(script, []byte("#!/bin/sh\nprintf 'echo one\\n\\necho two\\n' > \"$1\"\n"), 0755))
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", script)
+
+	le := createViEditor("old\x1bv")
+	assert.Equal([]string{ "echo one", "", "echo two" }, readLines(t, le, 3))
+	assert.Equal([]string{ "echo one", "echo two" }, le.History)
+
+	t.Setenv("EDITOR", filepath.Join(dir, "missing"))
+	le = createViEditor("old\x1bvx\r")
+	assert.Equal([]string{ "ol" }, readLines(t, le, 1))
+}
+
+// waitFor polls for the file, giving up after a few seconds
+func waitFor(name string) bool {
+	for i := 0; i < 500; i++ {
+		if _, err := os.Stat(name); err == nil {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestLineEditor_ViEditor_PrintWhileOpen(t *testing.T) {
+	assert := objects.NewTestAssertions(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "editor.sh")
+	assert.Nil(ioutil.WriteFile(script, []byte("#!/bin/sh\ntouch \"" + dir + "/open\"\n" +
+		"while [ ! -e \"" + dir + "/done\" ]; do sleep 0.01; done\nprintf 'echo hi\\n' > \"$1\"\n"), 0755))
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", script)
+
+	le := createViEditor("old\x1bv")
+	lines := make(chan []string)
+	go func() { lines <- readLines(t, le, 1) }()
+	assert.True(waitFor(filepath.Join(dir, "open")))
+
+	printed := make(chan bool)
+	go func() {
+		le.PrintAbove("note")
+		printed <- true
+	}()
+	select {
+	case <-printed:
+	case <-time.After(5 * time.Second):
+		t.Error("printing waited for the editor")
+	}
+
+	assert.Nil(ioutil.WriteFile(filepath.Join(dir, "done"), nil, 0644))
+	assert.Equal([]string{ "echo hi" }, <-lines)
+}